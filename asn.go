@@ -0,0 +1,129 @@
+package cymruasn
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// LookupASNs performs a bulk lookup of AS descriptions for the given ASNs.
+// It returns an ASNResponse containing successful results and any lookup
+// errors. The function returns a non-nil error only for connection-level
+// failures.
+func (c *Client) LookupASNs(ctx context.Context, asns []uint32) (*ASNResponse, error) {
+	if len(asns) == 0 {
+		return &ASNResponse{}, nil
+	}
+
+	request := buildASNRequest(asns)
+
+	if err := c.waitRateLimit(ctx, len(asns)); err != nil {
+		return nil, err
+	}
+
+	response, _, err := c.fetchWhois(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	results, parseErrs, err := parseLines(response, parseASNLine)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ASNResponse{
+		Results:     results,
+		Errors:      matchResultsToASNs(asns, results),
+		ParseErrors: parseErrs,
+	}, nil
+}
+
+// ParseASN parses an AS number written either as a plain number or with an
+// "AS" prefix, such as "15169" or "AS15169".
+func ParseASN(s string) (uint32, error) {
+	digits := strings.TrimSpace(s)
+	if len(digits) > 2 && strings.EqualFold(digits[:2], "AS") {
+		digits = digits[2:]
+	}
+
+	asn, err := strconv.ParseUint(digits, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid ASN: %s", s)
+	}
+
+	return uint32(asn), nil
+}
+
+// buildASNRequest creates the bulk whois request payload for ASN queries.
+// Verbose mode is always used so the country, registry and allocation date
+// are returned alongside the AS name.
+func buildASNRequest(asns []uint32) []byte {
+	var buf bytes.Buffer
+
+	buf.WriteString("begin\n")
+	buf.WriteString("verbose\n")
+
+	for _, asn := range asns {
+		fmt.Fprintf(&buf, "AS%d\n", asn)
+	}
+
+	buf.WriteString("end\n")
+
+	return buf.Bytes()
+}
+
+// parseASNLine parses a single pipe-delimited ASN result line.
+// Expected format: AS | CC | Registry | Allocated | AS Name
+func parseASNLine(line string) (ASInfo, error) {
+	parts, err := splitLine(line)
+	if err != nil {
+		return ASInfo{}, err
+	}
+
+	asn, err := strconv.ParseUint(parts[0], 10, 32)
+	if err != nil {
+		return ASInfo{}, err
+	}
+
+	info := ASInfo{ASN: uint32(asn)}
+
+	if len(parts) < 5 {
+		info.ASName = parts[len(parts)-1]
+		return info, nil
+	}
+
+	info.CountryCode = parts[1]
+	info.Registry = parts[2]
+
+	allocated, err := parseAllocated(parts[3])
+	if err != nil {
+		return ASInfo{}, err
+	}
+	info.Allocated = allocated
+
+	info.ASName = parts[4]
+
+	return info, nil
+}
+
+// matchResultsToASNs checks which requested ASNs are missing from results.
+func matchResultsToASNs(requested []uint32, results []ASInfo) []ASNLookupError {
+	resultMap := make(map[uint32]bool)
+	for _, r := range results {
+		resultMap[r.ASN] = true
+	}
+
+	var errs []ASNLookupError
+	for _, asn := range requested {
+		if !resultMap[asn] {
+			errs = append(errs, ASNLookupError{
+				ASN: asn,
+				Err: fmt.Errorf("no result returned for ASN: %d", asn),
+			})
+		}
+	}
+
+	return errs
+}
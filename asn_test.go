@@ -0,0 +1,179 @@
+package cymruasn
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestParseASN(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    uint32
+		wantErr bool
+	}{
+		{"15169", 15169, false},
+		{"AS15169", 15169, false},
+		{"as13335", 13335, false},
+		{" 4200000000 ", 4200000000, false},
+		{"AS", 0, true},
+		{"ASN15169", 0, true},
+		{"4294967296", 0, true},
+		{"-1", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseASN(tt.input)
+
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseASN(%q) expected error, got nil", tt.input)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Errorf("ParseASN(%q) unexpected error: %v", tt.input, err)
+				return
+			}
+
+			if got != tt.want {
+				t.Errorf("ParseASN(%q) = %d, want %d", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildASNRequest(t *testing.T) {
+	request := buildASNRequest([]uint32{15169, 13335})
+
+	expected := "begin\nverbose\nAS15169\nAS13335\nend\n"
+	if string(request) != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, string(request))
+	}
+}
+
+func TestParseASNLine(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		want    ASInfo
+		wantErr bool
+	}{
+		{
+			name: "verbose line",
+			line: "15169   | US | arin     | 2000-03-30 | GOOGLE, US",
+			want: ASInfo{
+				ASN:         15169,
+				CountryCode: "US",
+				Registry:    "arin",
+				Allocated:   time.Date(2000, 3, 30, 0, 0, 0, 0, time.UTC),
+				ASName:      "GOOGLE, US",
+			},
+		},
+		{
+			name: "name only",
+			line: "13335 | CLOUDFLARENET, US",
+			want: ASInfo{
+				ASN:    13335,
+				ASName: "CLOUDFLARENET, US",
+			},
+		},
+		{
+			name:    "invalid - no pipe",
+			line:    "15169",
+			wantErr: true,
+		},
+		{
+			name:    "invalid ASN",
+			line:    "NA | ZZ | other | | NA",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseASNLine(tt.line)
+
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected error, got nil")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+
+			if got.ASN != tt.want.ASN {
+				t.Errorf("ASN: got %d, want %d", got.ASN, tt.want.ASN)
+			}
+			if got.CountryCode != tt.want.CountryCode {
+				t.Errorf("CountryCode: got %s, want %s", got.CountryCode, tt.want.CountryCode)
+			}
+			if got.Registry != tt.want.Registry {
+				t.Errorf("Registry: got %s, want %s", got.Registry, tt.want.Registry)
+			}
+			if !got.Allocated.Equal(tt.want.Allocated) {
+				t.Errorf("Allocated: got %v, want %v", got.Allocated, tt.want.Allocated)
+			}
+			if got.ASName != tt.want.ASName {
+				t.Errorf("ASName: got %s, want %s", got.ASName, tt.want.ASName)
+			}
+		})
+	}
+}
+
+func TestLookupASNsEmptyList(t *testing.T) {
+	c := NewClient()
+	resp, err := c.LookupASNs(context.Background(), nil)
+
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if resp == nil {
+		t.Fatal("expected non-nil response")
+	}
+	if len(resp.Results) != 0 {
+		t.Errorf("expected 0 results, got %d", len(resp.Results))
+	}
+}
+
+func TestLookupASNsWithMockServer(t *testing.T) {
+	mockResponse := `Bulk mode; whois.cymru.com [2024-01-15 12:00:00 +0000]
+AS      | CC | Registry | Allocated  | AS Name
+15169   | US | arin     | 2000-03-30 | GOOGLE, US
+13335   | US | arin     | 2010-07-14 | CLOUDFLARENET, US
+`
+	server, port := startMockServer(t, mockResponse)
+
+	c := NewClient(
+		WithServer(server),
+		WithPort(port),
+		WithTimeout(5*time.Second),
+	)
+
+	resp, err := c.LookupASNs(context.Background(), []uint32{15169, 13335, 64512})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(resp.Results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(resp.Results))
+	}
+
+	if resp.Results[0].ASName != "GOOGLE, US" {
+		t.Errorf("expected AS name GOOGLE, US, got %s", resp.Results[0].ASName)
+	}
+
+	if len(resp.Errors) != 1 {
+		t.Fatalf("expected 1 error, got %d", len(resp.Errors))
+	}
+
+	if resp.Errors[0].ASN != 64512 {
+		t.Errorf("expected error for ASN 64512, got %d", resp.Errors[0].ASN)
+	}
+}
@@ -0,0 +1,155 @@
+package cymruasn
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// DefaultBatchSize is the default maximum number of IPs sent to the backend
+// in one query.
+const DefaultBatchSize = 10000
+
+// DefaultConcurrency is the default number of batches queried at once.
+const DefaultConcurrency = 1
+
+// batchResult is the outcome of querying one batch.
+type batchResult struct {
+	results   []Result
+	errs      []LookupError
+	parseErrs []ParseError
+	attempts  int
+	err       error
+}
+
+// queryBatches splits the IPs into batches, queries them on at most
+// c.concurrency connections at once and merges the outcomes in batch
+// order. The IPs of a failed batch are reported as lookup errors carrying
+// the batch error. The returned error is non-nil only when every batch
+// failed.
+func (c *Client) queryBatches(ctx context.Context, ips []string) (batchResult, error) {
+	batches := splitBatches(ips, c.batchSize)
+	outcomes := make([]batchResult, len(batches))
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, max(c.concurrency, 1))
+
+	for i, batch := range batches {
+		wg.Add(1)
+		sem <- struct{}{}
+
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			outcomes[i] = c.queryBatch(ctx, batch)
+		}()
+	}
+
+	wg.Wait()
+
+	var merged batchResult
+	var err error
+	failed := 0
+
+	for _, o := range outcomes {
+		if o.err != nil {
+			failed++
+			err = o.err
+		}
+		merged.results = append(merged.results, o.results...)
+		merged.errs = append(merged.errs, o.errs...)
+		merged.parseErrs = append(merged.parseErrs, o.parseErrs...)
+		merged.attempts += o.attempts
+	}
+
+	if failed == len(batches) {
+		return batchResult{}, err
+	}
+
+	return merged, nil
+}
+
+// queryBatch queries a single batch, retrying it according to the
+// client's retry policy.
+func (c *Client) queryBatch(ctx context.Context, ips []string) batchResult {
+	var results []Result
+	var parseErrs []ParseError
+	var failed IPErrors
+
+	attempts, err := c.withRetry(ctx, func() error {
+		if err := c.waitRateLimit(ctx, len(ips)); err != nil {
+			return err
+		}
+
+		var err error
+		results, parseErrs, err = c.backend.Query(ctx, ips)
+		failed = nil
+		if errors.As(err, &failed) {
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		errs := make([]LookupError, len(ips))
+		for i, ip := range ips {
+			errs[i] = LookupError{IP: ip, Err: err}
+		}
+		return batchResult{errs: errs, attempts: attempts, err: err}
+	}
+
+	c.trimResults(results)
+	merged, lookupErrs := c.matchResultsToIPs(ips, results)
+	lookupErrs = withIPErrors(lookupErrs, failed)
+	return batchResult{results: merged, errs: lookupErrs, parseErrs: parseErrs, attempts: attempts}
+}
+
+// trimResults clears the fields only reported to verbose clients, which
+// some backends, such as the DNS interface, always fill in.
+func (c *Client) trimResults(results []Result) {
+	if c.verbose {
+		return
+	}
+
+	for i := range results {
+		results[i].Registry = ""
+		results[i].Allocated = time.Time{}
+	}
+}
+
+// withIPErrors replaces the errors for missing IPs that the backend
+// reported as failed with the backend's error for each.
+func withIPErrors(errs []LookupError, failed IPErrors) []LookupError {
+	if len(failed) == 0 {
+		return errs
+	}
+
+	byIP := make(map[string]error, len(failed))
+	for _, e := range failed {
+		byIP[canonicalIP(e.IP)] = e.Err
+	}
+
+	for i, e := range errs {
+		if err, ok := byIP[canonicalIP(e.IP)]; ok {
+			errs[i].Err = err
+		}
+	}
+
+	return errs
+}
+
+// splitBatches splits the IPs into consecutive batches of at most size IPs.
+// A size of zero or less puts every IP in one batch.
+func splitBatches(ips []string, size int) [][]string {
+	if size <= 0 || len(ips) <= size {
+		return [][]string{ips}
+	}
+
+	var batches [][]string
+	for len(ips) > size {
+		batches = append(batches, ips[:size:size])
+		ips = ips[size:]
+	}
+	return append(batches, ips)
+}
@@ -0,0 +1,143 @@
+package cymruasn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// batchBackend answers every IP with AS64500, fails batches containing a
+// poisoned IP, and records how many queries run at once.
+type batchBackend struct {
+	poison string
+
+	mu       sync.Mutex
+	batches  [][]string
+	inFlight int
+	peak     int
+}
+
+var errBatchFailed = errors.New("batch failed")
+
+func (b *batchBackend) Query(_ context.Context, ips []string) ([]Result, []ParseError, error) {
+	b.mu.Lock()
+	b.batches = append(b.batches, ips)
+	b.inFlight++
+	b.peak = max(b.peak, b.inFlight)
+	b.mu.Unlock()
+
+	time.Sleep(10 * time.Millisecond)
+
+	b.mu.Lock()
+	b.inFlight--
+	b.mu.Unlock()
+
+	if slices.Contains(ips, b.poison) {
+		return nil, nil, errBatchFailed
+	}
+
+	results := make([]Result, len(ips))
+	for i, ip := range ips {
+		results[i] = Result{IP: ip, ASN: 64500, ASNs: []uint32{64500}}
+	}
+	return results, nil, nil
+}
+
+func testIPs(n int) []string {
+	ips := make([]string, n)
+	for i := range ips {
+		ips[i] = fmt.Sprintf("11.0.%d.%d", i/256, i%256)
+	}
+	return ips
+}
+
+func TestSplitBatches(t *testing.T) {
+	ips := testIPs(5)
+
+	tests := []struct {
+		size int
+		want []int
+	}{
+		{2, []int{2, 2, 1}},
+		{5, []int{5}},
+		{10, []int{5}},
+		{0, []int{5}},
+	}
+
+	for _, tt := range tests {
+		var got []int
+		for _, b := range splitBatches(ips, tt.size) {
+			got = append(got, len(b))
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("splitBatches(5 IPs, %d) sizes = %v, want %v", tt.size, got, tt.want)
+		}
+	}
+}
+
+func TestLookupBatches(t *testing.T) {
+	backend := &batchBackend{}
+	c := NewClient(WithBackend(backend), WithBatchSize(10), WithConcurrency(3))
+
+	ips := testIPs(95)
+	resp, err := c.Lookup(context.Background(), ips)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(backend.batches) != 10 {
+		t.Errorf("expected 10 batches, got %d", len(backend.batches))
+	}
+	if backend.peak > 3 {
+		t.Errorf("expected at most 3 concurrent batches, got %d", backend.peak)
+	}
+
+	if len(resp.Results) != len(ips) || len(resp.Errors) != 0 {
+		t.Fatalf("expected %d results and no errors, got %d and %d", len(ips), len(resp.Results), len(resp.Errors))
+	}
+
+	for i, r := range resp.Results {
+		if r.IP != ips[i] {
+			t.Fatalf("result %d is %s, want %s", i, r.IP, ips[i])
+		}
+	}
+}
+
+func TestLookupBatchFailure(t *testing.T) {
+	ips := testIPs(30)
+	backend := &batchBackend{poison: ips[15]}
+	c := NewClient(WithBackend(backend), WithBatchSize(10), WithConcurrency(2))
+
+	resp, err := c.Lookup(context.Background(), ips)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(resp.Results) != 20 {
+		t.Errorf("expected 20 results from the good batches, got %d", len(resp.Results))
+	}
+
+	if len(resp.Errors) != 10 {
+		t.Fatalf("expected 10 errors from the failed batch, got %d", len(resp.Errors))
+	}
+
+	for i, e := range resp.Errors {
+		if e.IP != ips[10+i] || !errors.Is(e.Err, errBatchFailed) {
+			t.Errorf("error %d = %s: %v, want %s: %v", i, e.IP, e.Err, ips[10+i], errBatchFailed)
+		}
+	}
+}
+
+func TestLookupAllBatchesFail(t *testing.T) {
+	ips := testIPs(5)
+	backend := &batchBackend{poison: ips[0]}
+	c := NewClient(WithBackend(backend), WithBatchSize(0))
+
+	if _, err := c.Lookup(context.Background(), ips); !errors.Is(err, errBatchFailed) {
+		t.Errorf("expected %v, got %v", errBatchFailed, err)
+	}
+}
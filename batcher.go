@@ -0,0 +1,181 @@
+package cymruasn
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBatchWindow is the default time a Batcher collects lookups before
+// sending them.
+const DefaultBatchWindow = 50 * time.Millisecond
+
+// DefaultMaxBatch is the default number of lookups that makes a Batcher
+// send its batch before the window ends.
+const DefaultMaxBatch = 1000
+
+// Batcher turns single-IP lookups from many goroutines into a few bulk
+// queries. Lookups arriving within a short window are collected and sent
+// together through the client, and each caller receives its own result.
+// A Batcher is safe for concurrent use.
+type Batcher struct {
+	client   *Client
+	window   time.Duration
+	maxBatch int
+
+	mu      sync.Mutex
+	pending []*batcherCall
+	gen     uint64
+	timer   *time.Timer
+}
+
+// batcherCall is a lookup waiting for its batch to be answered.
+type batcherCall struct {
+	ip     string
+	done   chan struct{}
+	result Result
+	err    error
+}
+
+// BatcherOption configures a Batcher.
+type BatcherOption func(*Batcher)
+
+// WithBatchWindow sets how long a Batcher collects lookups before sending
+// them, measured from the first lookup of a batch.
+func WithBatchWindow(window time.Duration) BatcherOption {
+	return func(b *Batcher) {
+		b.window = window
+	}
+}
+
+// WithMaxBatch sets the number of lookups that makes a Batcher send its
+// batch without waiting for the window to end.
+func WithMaxBatch(n int) BatcherOption {
+	return func(b *Batcher) {
+		b.maxBatch = n
+	}
+}
+
+// NewBatcher returns a Batcher that sends its batches through client.
+func NewBatcher(client *Client, opts ...BatcherOption) *Batcher {
+	b := &Batcher{
+		client:   client,
+		window:   DefaultBatchWindow,
+		maxBatch: DefaultMaxBatch,
+	}
+
+	for _, opt := range opts {
+		opt(b)
+	}
+
+	return b
+}
+
+// Lookup looks up a single IP as part of the current batch and waits for
+// its result. A failed lookup returns a LookupError. If ctx is done first,
+// Lookup returns ctx's error; the batch is still sent for the other
+// callers.
+func (b *Batcher) Lookup(ctx context.Context, ip string) (Result, error) {
+	ip = strings.TrimSpace(ip)
+	if !isValidIP(ip) {
+		return Result{}, LookupError{IP: ip, Err: fmt.Errorf("invalid IP address: %s", ip)}
+	}
+
+	call := &batcherCall{ip: ip, done: make(chan struct{})}
+	b.add(call)
+
+	select {
+	case <-call.done:
+		return call.result, call.err
+	case <-ctx.Done():
+		return Result{}, ctx.Err()
+	}
+}
+
+// add appends call to the current batch, starting the window for a new
+// batch and sending a full one.
+func (b *Batcher) add(call *batcherCall) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.pending = append(b.pending, call)
+
+	if len(b.pending) == 1 {
+		gen := b.gen
+		b.timer = time.AfterFunc(b.window, func() { b.flush(gen) })
+	}
+
+	if b.maxBatch > 0 && len(b.pending) >= b.maxBatch {
+		go b.send(b.take())
+	}
+}
+
+// flush sends batch gen when its window ends, unless it was already sent
+// for being full.
+func (b *Batcher) flush(gen uint64) {
+	b.mu.Lock()
+	if b.gen != gen {
+		b.mu.Unlock()
+		return
+	}
+	calls := b.take()
+	b.mu.Unlock()
+
+	b.send(calls)
+}
+
+// take removes and returns the current batch. The caller holds b.mu.
+func (b *Batcher) take() []*batcherCall {
+	calls := b.pending
+	b.pending = nil
+	b.gen++
+	b.timer.Stop()
+	return calls
+}
+
+// send looks up a batch and delivers each caller its result. The query is
+// not tied to any caller's context, since callers may give up
+// independently.
+func (b *Batcher) send(calls []*batcherCall) {
+	ips := make([]string, len(calls))
+	for i, call := range calls {
+		ips[i] = call.ip
+	}
+
+	resp, err := b.client.Lookup(context.Background(), ips)
+
+	errs := make(map[string]LookupError)
+	if resp != nil {
+		for _, e := range resp.Errors {
+			errs[canonicalIP(e.IP)] = e
+		}
+	}
+
+	for _, call := range calls {
+		call.result, call.err = batchOutcome(call.ip, resp, errs, err)
+		close(call.done)
+	}
+}
+
+// batchOutcome picks the result or lookup error for ip out of a batch
+// response, or attributes the batch error to it.
+func batchOutcome(ip string, resp *Response, errs map[string]LookupError, err error) (Result, error) {
+	if err != nil {
+		return Result{}, LookupError{IP: ip, Err: err}
+	}
+
+	if r, ok := resp.Get(ip); ok {
+		result := *r
+		result.IP = ip
+		return result, nil
+	}
+
+	if e, ok := errs[canonicalIP(ip)]; ok {
+		e.IP = ip
+		return Result{}, e
+	}
+
+	return Result{}, LookupError{IP: ip, Err: fmt.Errorf("%w: %s", ErrNoResult, ip)}
+}
@@ -0,0 +1,127 @@
+package cymruasn
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBatcherCollects(t *testing.T) {
+	backend := &batchBackend{}
+	b := NewBatcher(NewClient(WithBackend(backend)), WithBatchWindow(50*time.Millisecond))
+
+	ips := testIPs(50)
+
+	var wg sync.WaitGroup
+	for _, ip := range ips {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r, err := b.Lookup(context.Background(), ip)
+			if err != nil {
+				t.Errorf("unexpected error for %s: %v", ip, err)
+				return
+			}
+			if r.IP != ip || r.ASN != 64500 {
+				t.Errorf("unexpected result for %s: %+v", ip, r)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(backend.batches) != 1 || len(backend.batches[0]) != len(ips) {
+		t.Errorf("expected one bulk query of %d IPs, got %d queries", len(ips), len(backend.batches))
+	}
+}
+
+func TestBatcherMaxBatch(t *testing.T) {
+	backend := &batchBackend{}
+	b := NewBatcher(NewClient(WithBackend(backend)), WithBatchWindow(time.Hour), WithMaxBatch(10))
+
+	var wg sync.WaitGroup
+	for _, ip := range testIPs(30) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := b.Lookup(context.Background(), ip); err != nil {
+				t.Errorf("unexpected error for %s: %v", ip, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(backend.batches) != 3 {
+		t.Errorf("expected 3 full batches, got %d", len(backend.batches))
+	}
+}
+
+func TestBatcherErrors(t *testing.T) {
+	backend := &stubBackend{
+		results: map[string]Result{
+			"8.8.8.8": {ASN: 15169, BGPPrefix: "8.8.8.0/24"},
+		},
+	}
+	b := NewBatcher(NewClient(WithBackend(backend)), WithBatchWindow(time.Millisecond))
+
+	var lookupErr LookupError
+
+	if _, err := b.Lookup(context.Background(), "bogus"); !errors.As(err, &lookupErr) {
+		t.Errorf("expected a LookupError for an invalid IP, got %v", err)
+	}
+
+	if _, err := b.Lookup(context.Background(), "9.9.9.9"); !errors.As(err, &lookupErr) || lookupErr.IP != "9.9.9.9" {
+		t.Errorf("expected a LookupError for 9.9.9.9, got %v", err)
+	}
+
+	if len(backend.queries) != 1 {
+		t.Errorf("expected the invalid IP not to be queried, got %v", backend.queries)
+	}
+}
+
+func TestBatcherBatchFailure(t *testing.T) {
+	ips := testIPs(5)
+	backend := &batchBackend{poison: ips[0]}
+	b := NewBatcher(NewClient(WithBackend(backend)), WithBatchWindow(20*time.Millisecond))
+
+	var wg sync.WaitGroup
+	for _, ip := range ips {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := b.Lookup(context.Background(), ip)
+
+			var lookupErr LookupError
+			if !errors.As(err, &lookupErr) || lookupErr.IP != ip || !errors.Is(err, errBatchFailed) {
+				t.Errorf("expected a LookupError carrying the batch error for %s, got %v", ip, err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestBatcherContextCanceled(t *testing.T) {
+	backend := &batchBackend{}
+	b := NewBatcher(NewClient(WithBackend(backend)), WithBatchWindow(50*time.Millisecond))
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if _, err := b.Lookup(ctx, "11.0.0.1"); !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context.Canceled, got %v", err)
+		}
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+
+	if _, err := b.Lookup(context.Background(), "11.0.0.2"); err != nil {
+		t.Errorf("expected the other caller to succeed, got %v", err)
+	}
+
+	wg.Wait()
+}
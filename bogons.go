@@ -0,0 +1,131 @@
+package cymruasn
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/netip"
+	"path/filepath"
+	"strings"
+)
+
+// BogonList holds prefixes from Team Cymru's fullbogons lists, which cover
+// special-purpose space and address space not yet allocated by an RIR. The
+// lists change as space is allocated, so they should be refreshed
+// regularly. A BogonList must not be modified concurrently with lookups.
+type BogonList struct {
+	table *prefixTable[string]
+}
+
+// NewBogonList returns an empty bogon list.
+func NewBogonList() *BogonList {
+	return &BogonList{table: newPrefixTable[string]()}
+}
+
+// LoadBogons loads one or more fullbogons files, such as
+// fullbogons-ipv4.txt and fullbogons-ipv6.txt, plain or gzip-compressed.
+func LoadBogons(paths ...string) (*BogonList, error) {
+	b := NewBogonList()
+
+	for _, path := range paths {
+		if err := b.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	return b, nil
+}
+
+// loadFile adds the prefixes of a single file, naming them after the file.
+func (b *BogonList) loadFile(path string) error {
+	f, err := openDataFile(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := b.Read(f, filepath.Base(path)); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	return nil
+}
+
+// Read adds the prefixes of a fullbogons list read from r. The list has
+// one prefix per line; lines starting with "#" are comments. source names
+// the list in bogon reasons.
+func (b *BogonList) Read(r io.Reader, source string) error {
+	r, err := maybeGunzip(r)
+	if err != nil {
+		return err
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(line)
+		if err != nil {
+			return fmt.Errorf("bogons line %d: %w", lineNum, err)
+		}
+
+		b.table.insert(prefix, source)
+	}
+
+	return scanner.Err()
+}
+
+// Len returns the number of prefixes loaded.
+func (b *BogonList) Len() int {
+	return b.table.len()
+}
+
+// Lookup reports whether ip lies in a bogon prefix and, if so, returns a
+// reason naming the prefix and the list it came from.
+func (b *BogonList) Lookup(ip string) (string, bool) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return "", false
+	}
+
+	prefix, source, ok := b.table.lookup(addr)
+	if !ok {
+		return "", false
+	}
+
+	if source == "" {
+		return fmt.Sprintf("bogon prefix %s", prefix), true
+	}
+	return fmt.Sprintf("bogon prefix %s listed in %s", prefix, source), true
+}
+
+// flagBogons sets the Bogon reason of every result and lookup error in resp
+// whose IP lies in a bogon prefix.
+func (c *Client) flagBogons(resp *Response) {
+	for i := range resp.Results {
+		resp.Results[i].Bogon = c.bogonReason(resp.Results[i].IP)
+	}
+
+	for i := range resp.Errors {
+		resp.Errors[i].Bogon = c.bogonReason(resp.Errors[i].IP)
+	}
+}
+
+// bogonReason returns why ip is a bogon, or an empty string when it is not
+// or the client has no bogon list.
+func (c *Client) bogonReason(ip string) string {
+	if c.bogons == nil {
+		return ""
+	}
+
+	reason, _ := c.bogons.Lookup(ip)
+	return reason
+}
@@ -0,0 +1,108 @@
+package cymruasn
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testFullbogonsIPv4 = `# last updated 1705300000 (Mon Jan 15 06:26:40 2024 GMT)
+0.0.0.0/8
+10.0.0.0/8
+41.62.0.0/16
+`
+
+const testFullbogonsIPv6 = `# last updated 1705300000 (Mon Jan 15 06:26:40 2024 GMT)
+2001:db8::/32
+2c0f:f6d0::/28
+`
+
+func TestBogonList(t *testing.T) {
+	dir := t.TempDir()
+	v4 := filepath.Join(dir, "fullbogons-ipv4.txt")
+	v6 := filepath.Join(dir, "fullbogons-ipv6.txt")
+
+	if err := os.WriteFile(v4, []byte(testFullbogonsIPv4), 0o644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	if err := os.WriteFile(v6, []byte(testFullbogonsIPv6), 0o644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	b, err := LoadBogons(v4, v6)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if b.Len() != 5 {
+		t.Errorf("expected 5 prefixes, got %d", b.Len())
+	}
+
+	tests := []struct {
+		ip     string
+		reason string
+		wantOK bool
+	}{
+		{"41.62.1.1", "bogon prefix 41.62.0.0/16 listed in fullbogons-ipv4.txt", true},
+		{"10.1.1.1", "bogon prefix 10.0.0.0/8 listed in fullbogons-ipv4.txt", true},
+		{"2c0f:f6d0::1", "bogon prefix 2c0f:f6d0::/28 listed in fullbogons-ipv6.txt", true},
+		{"8.8.8.8", "", false},
+		{"not-an-ip", "", false},
+	}
+
+	for _, tt := range tests {
+		reason, ok := b.Lookup(tt.ip)
+		if ok != tt.wantOK || reason != tt.reason {
+			t.Errorf("Lookup(%s) = %q, %v; want %q, %v", tt.ip, reason, ok, tt.reason, tt.wantOK)
+		}
+	}
+}
+
+func TestBogonListInvalid(t *testing.T) {
+	b := NewBogonList()
+	if err := b.Read(strings.NewReader("10.0.0.0/8\nnot-a-prefix\n"), "test"); err == nil {
+		t.Error("expected error for invalid prefix, got nil")
+	}
+}
+
+func TestLookupFlagsBogons(t *testing.T) {
+	bogons := NewBogonList()
+	if err := bogons.Read(strings.NewReader(testFullbogonsIPv4), "fullbogons-ipv4.txt"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	backend := &stubBackend{
+		results: map[string]Result{
+			"8.8.8.8":   {ASN: 15169, BGPPrefix: "8.8.8.0/24"},
+			"41.62.1.1": {BGPPrefix: "NA"},
+		},
+	}
+
+	c := NewClient(WithBackend(backend), WithBogons(bogons))
+
+	resp, err := c.Lookup(context.Background(), []string{"8.8.8.8", "41.62.1.1", "41.62.2.2", "10.0.0.1"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	flagged := map[string]string{}
+	for _, r := range resp.Results {
+		flagged[r.IP] = r.Bogon
+	}
+
+	if flagged["8.8.8.8"] != "" {
+		t.Errorf("expected 8.8.8.8 not to be flagged, got %q", flagged["8.8.8.8"])
+	}
+	if flagged["41.62.1.1"] == "" {
+		t.Error("expected 41.62.1.1 to be flagged")
+	}
+	if flagged["10.0.0.1"] == "" {
+		t.Error("expected special-purpose 10.0.0.1 to be flagged")
+	}
+
+	if len(resp.Errors) != 1 || resp.Errors[0].IP != "41.62.2.2" || resp.Errors[0].Bogon == "" {
+		t.Errorf("expected flagged error for 41.62.2.2, got %+v", resp.Errors)
+	}
+}
@@ -0,0 +1,234 @@
+package cymruasn
+
+import (
+	"container/list"
+	"net/netip"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Cache is an in-memory cache of lookup results, safe for concurrent use
+// by several clients. Results are stored by their announced BGP prefix,
+// so any later IP inside a cached prefix is answered without a query.
+// Results for unrouted IPs are stored by IP with their own TTL, and
+// results whose prefix is synthetic by IP with the normal TTL. The least
+// recently used entries are evicted once the cache is full.
+//
+// A more specific prefix announced inside a cached one is not seen until
+// the cached entry expires. Entries stored by a client without WithVerbose
+// lack the registry and allocation date, so verbose clients treat them as
+// misses.
+type Cache struct {
+	mu          sync.Mutex
+	size        int
+	ttl         time.Duration
+	negativeTTL time.Duration
+	clock       clock
+
+	table *prefixTable[*list.Element]
+	lru   *list.List
+}
+
+// cacheEntry is a cached result. The key is the announced prefix, or a
+// single-address prefix for negative entries. Verbose is set when the
+// result carries the registry and allocation date.
+type cacheEntry struct {
+	key      netip.Prefix
+	result   Result
+	expires  time.Time
+	negative bool
+	verbose  bool
+}
+
+// NewCache returns a cache holding at most size entries, or any number if
+// size is zero or less. Results with an announced prefix are kept for ttl
+// and results for unrouted IPs for negativeTTL; a TTL of zero or less
+// disables caching of that kind of result.
+func NewCache(size int, ttl, negativeTTL time.Duration) *Cache {
+	return &Cache{
+		size:        size,
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+		clock:       realClock{},
+		table:       newPrefixTable[*list.Element](),
+		lru:         list.New(),
+	}
+}
+
+// WithCache answers lookups from cache where possible and stores the
+// results of queries in it.
+func WithCache(cache *Cache) Option {
+	return func(c *Client) {
+		c.cache = cache
+	}
+}
+
+// Len returns the number of entries in the cache, including expired
+// entries not yet removed.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+// Purge removes every entry from the cache.
+func (c *Cache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.table = newPrefixTable[*list.Element]()
+	c.lru.Init()
+}
+
+// get returns the cached result for ip, marked as Cached. A verbose lookup
+// is only answered by a verbose entry; other lookups get the result without
+// the verbose fields, as a query would return it.
+func (c *Cache) get(ip string, verbose bool) (Result, bool) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return Result{}, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.clock.Now()
+
+	for {
+		_, el, ok := c.table.lookup(addr)
+		if !ok {
+			return Result{}, false
+		}
+
+		e := el.Value.(*cacheEntry)
+		if now.After(e.expires) {
+			c.removeElement(el)
+			continue
+		}
+
+		if verbose && !e.verbose {
+			return Result{}, false
+		}
+
+		c.lru.MoveToFront(el)
+
+		r := e.result
+		r.IP = ip
+		r.ASNs = slices.Clone(r.ASNs)
+		r.Cached = true
+		if !verbose {
+			r.Registry = ""
+			r.Allocated = time.Time{}
+		}
+		return r, true
+	}
+}
+
+// put stores r, looked up verbosely or not, replacing any entry with the
+// same key.
+func (c *Cache) put(r Result, verbose bool) {
+	if r.SpecialPurpose != nil || r.Cached {
+		return
+	}
+
+	addr, err := netip.ParseAddr(r.IP)
+	if err != nil {
+		return
+	}
+	addr = addr.Unmap().WithZone("")
+
+	e := &cacheEntry{result: r, verbose: verbose}
+
+	if p, err := netip.ParsePrefix(r.BGPPrefix); err == nil && normalizePrefix(p).Contains(addr) {
+		if c.ttl <= 0 {
+			return
+		}
+		e.key = normalizePrefix(p)
+		if r.SyntheticPrefix {
+			// The prefix may cover addresses the result does not apply
+			// to.
+			e.key = netip.PrefixFrom(addr, addr.BitLen())
+		}
+	} else {
+		if c.negativeTTL <= 0 {
+			return
+		}
+		e.key = netip.PrefixFrom(addr, addr.BitLen())
+		e.negative = true
+	}
+
+	// The IP and per-IP annotations do not apply to other IPs in the
+	// prefix.
+	e.result.IP = ""
+	e.result.Bogon = ""
+	e.result.Delegation = nil
+	e.result.Server = ""
+	e.result.ASNs = slices.Clone(r.ASNs)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ttl := c.ttl
+	if e.negative {
+		ttl = c.negativeTTL
+	}
+	e.expires = c.clock.Now().Add(ttl)
+
+	c.insert(e)
+}
+
+// insert adds e as the most recently used entry, replacing any entry with
+// the same key and evicting the least recently used entries beyond the
+// size bound. The caller holds c.mu.
+func (c *Cache) insert(e *cacheEntry) {
+	if el, ok := c.table.get(e.key); ok {
+		el.Value = e
+		c.lru.MoveToFront(el)
+		return
+	}
+
+	c.table.insert(e.key, c.lru.PushFront(e))
+
+	for c.size > 0 && c.lru.Len() > c.size {
+		c.removeElement(c.lru.Back())
+	}
+}
+
+// removeElement removes an entry. The caller holds c.mu.
+func (c *Cache) removeElement(el *list.Element) {
+	c.lru.Remove(el)
+	c.table.remove(el.Value.(*cacheEntry).key)
+}
+
+// fromCache splits the IPs into those that must be queried and the results
+// for those answered from the client's cache.
+func (c *Client) fromCache(ips []string) ([]string, []Result) {
+	if c.cache == nil {
+		return ips, nil
+	}
+
+	var uncached []string
+	var results []Result
+
+	for _, ip := range ips {
+		if r, ok := c.cache.get(ip, c.verbose); ok {
+			results = append(results, r)
+			continue
+		}
+		uncached = append(uncached, ip)
+	}
+
+	return uncached, results
+}
+
+// storeInCache stores query results in the client's cache.
+func (c *Client) storeInCache(results []Result) {
+	if c.cache == nil {
+		return
+	}
+
+	for _, r := range results {
+		c.cache.put(r, c.verbose)
+	}
+}
@@ -0,0 +1,235 @@
+package cymruasn
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestCache(size int) (*Cache, *fakeClock) {
+	cache := NewCache(size, time.Hour, time.Minute)
+	clock := newFakeClock()
+	cache.clock = clock
+	return cache, clock
+}
+
+func TestCachePrefix(t *testing.T) {
+	cache, clock := newTestCache(10)
+
+	cache.put(Result{IP: "8.8.8.8", ASN: 15169, BGPPrefix: "8.8.8.0/24", Bogon: "x"}, false)
+
+	r, ok := cache.get("8.8.8.200", false)
+	if !ok {
+		t.Fatal("expected a cached result for an IP inside the prefix")
+	}
+	if r.IP != "8.8.8.200" || r.ASN != 15169 || !r.Cached || r.Bogon != "" {
+		t.Errorf("unexpected cached result %+v", r)
+	}
+
+	if _, ok := cache.get("8.8.9.1", false); ok {
+		t.Error("expected no cached result outside the prefix")
+	}
+
+	clock.now = clock.now.Add(2 * time.Hour)
+
+	if _, ok := cache.get("8.8.8.8", false); ok {
+		t.Error("expected the entry to expire")
+	}
+	if cache.Len() != 0 {
+		t.Errorf("expected the expired entry to be removed, have %d", cache.Len())
+	}
+}
+
+func TestCacheLongestMatch(t *testing.T) {
+	cache, clock := newTestCache(10)
+
+	cache.put(Result{IP: "8.0.0.1", ASN: 3356, BGPPrefix: "8.0.0.0/9"}, false)
+	clock.now = clock.now.Add(30 * time.Minute)
+	cache.put(Result{IP: "8.8.8.8", ASN: 15169, BGPPrefix: "8.8.8.0/24"}, false)
+
+	if r, _ := cache.get("8.8.8.1", false); r.ASN != 15169 {
+		t.Errorf("expected the more specific prefix, got AS%d", r.ASN)
+	}
+
+	// Once the /9 expires the /24 still answers, and the rest of the /9
+	// does not.
+	clock.now = clock.now.Add(45 * time.Minute)
+
+	if r, _ := cache.get("8.8.8.1", false); r.ASN != 15169 {
+		t.Errorf("expected the /24 to remain, got AS%d", r.ASN)
+	}
+	if _, ok := cache.get("8.1.1.1", false); ok {
+		t.Error("expected the /9 to have expired")
+	}
+}
+
+func TestCacheNegative(t *testing.T) {
+	cache, clock := newTestCache(10)
+
+	cache.put(Result{IP: "11.0.0.1", BGPPrefix: "NA", CountryCode: "ZZ", ASName: "NA"}, false)
+
+	if r, ok := cache.get("11.0.0.1", false); !ok || r.ASN != 0 || !r.Cached {
+		t.Errorf("expected a negative cached result, got %+v, %v", r, ok)
+	}
+	if _, ok := cache.get("11.0.0.2", false); ok {
+		t.Error("expected negative entries to cover a single IP")
+	}
+
+	clock.now = clock.now.Add(2 * time.Minute)
+
+	if _, ok := cache.get("11.0.0.1", false); ok {
+		t.Error("expected the negative entry to expire with its own TTL")
+	}
+
+	disabled := NewCache(10, time.Hour, 0)
+	disabled.put(Result{IP: "11.0.0.1", BGPPrefix: "NA"}, false)
+	if disabled.Len() != 0 {
+		t.Error("expected negative caching to be disabled")
+	}
+}
+
+func TestCacheEviction(t *testing.T) {
+	cache, _ := newTestCache(2)
+
+	cache.put(Result{IP: "1.0.0.1", ASN: 1, BGPPrefix: "1.0.0.0/24"}, false)
+	cache.put(Result{IP: "2.0.0.1", ASN: 2, BGPPrefix: "2.0.0.0/24"}, false)
+	cache.get("1.0.0.1", false)
+	cache.put(Result{IP: "3.0.0.1", ASN: 3, BGPPrefix: "3.0.0.0/24"}, false)
+
+	if cache.Len() != 2 {
+		t.Errorf("expected 2 entries, got %d", cache.Len())
+	}
+	if _, ok := cache.get("2.0.0.1", false); ok {
+		t.Error("expected the least recently used entry to be evicted")
+	}
+	if _, ok := cache.get("1.0.0.1", false); !ok {
+		t.Error("expected the recently used entry to remain")
+	}
+}
+
+func TestLookupWithCache(t *testing.T) {
+	backend := &stubBackend{
+		results: map[string]Result{
+			"8.8.8.8":  {ASN: 15169, BGPPrefix: "8.8.8.0/24"},
+			"11.0.0.1": {BGPPrefix: "NA"},
+		},
+	}
+
+	c := NewClient(WithBackend(backend), WithCache(NewCache(100, time.Hour, time.Minute)))
+
+	if _, err := c.Lookup(context.Background(), []string{"8.8.8.8", "11.0.0.1"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	resp, err := c.Lookup(context.Background(), []string{"8.8.8.4", "11.0.0.1", "8.8.4.4"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(backend.queries) != 2 || len(backend.queries[1]) != 1 || backend.queries[1][0] != "8.8.4.4" {
+		t.Errorf("expected only 8.8.4.4 to be queried again, got %v", backend.queries)
+	}
+
+	if r, ok := resp.Get("8.8.8.4"); !ok || !r.Cached || r.ASN != 15169 {
+		t.Errorf("expected a cached result for 8.8.8.4, got %+v", r)
+	}
+	if r, ok := resp.Get("11.0.0.1"); !ok || !r.Cached {
+		t.Errorf("expected a cached negative result for 11.0.0.1, got %+v", r)
+	}
+}
+
+func TestLookupWithSharedCacheVerbosity(t *testing.T) {
+	cache := NewCache(100, time.Hour, time.Minute)
+	allocated := time.Date(2000, 3, 30, 0, 0, 0, 0, time.UTC)
+
+	brief := &stubBackend{results: map[string]Result{"8.8.8.8": {ASN: 15169, BGPPrefix: "8.8.8.0/24"}}}
+	verbose := &stubBackend{results: map[string]Result{"8.8.8.8": {ASN: 15169, BGPPrefix: "8.8.8.0/24", Registry: "arin", Allocated: allocated}}}
+
+	briefClient := NewClient(WithBackend(brief), WithCache(cache))
+	verboseClient := NewClient(WithBackend(verbose), WithCache(cache), WithVerbose(true))
+
+	if _, err := briefClient.Lookup(context.Background(), []string{"8.8.8.8"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The entry stored without the verbose fields is a miss for a verbose
+	// client, which queries and stores a verbose entry.
+	resp, err := verboseClient.Lookup(context.Background(), []string{"8.8.8.8"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if r, ok := resp.Get("8.8.8.8"); !ok || r.Cached || r.Registry != "arin" {
+		t.Errorf("expected a queried verbose result, got %+v", r)
+	}
+
+	resp, err = verboseClient.Lookup(context.Background(), []string{"8.8.8.4"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if r, ok := resp.Get("8.8.8.4"); !ok || !r.Cached || r.Registry != "arin" || !r.Allocated.Equal(allocated) {
+		t.Errorf("expected a cached verbose result, got %+v", r)
+	}
+
+	// The verbose entry answers the other client without the verbose
+	// fields.
+	resp, err = briefClient.Lookup(context.Background(), []string{"8.8.8.4"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if r, ok := resp.Get("8.8.8.4"); !ok || !r.Cached || r.Registry != "" || !r.Allocated.IsZero() {
+		t.Errorf("expected a cached result without verbose fields, got %+v", r)
+	}
+
+	if len(brief.queries) != 1 || len(verbose.queries) != 1 {
+		t.Errorf("expected one query per client, got %v and %v", brief.queries, verbose.queries)
+	}
+}
+
+func TestLookupWithCacheSyntheticPrefix(t *testing.T) {
+	// The second range is not a prefix; its covering 1.0.0.0/23 takes in
+	// the first range too.
+	data := "1.0.0.0\t1.0.0.127\t64500\tUS\tFIRST\n" +
+		"1.0.0.128\t1.0.1.255\t64501\tUS\tSECOND\n"
+
+	iptoasn, err := ReadIPtoASN(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	backend := &countingBackend{Backend: iptoasn}
+	c := NewClient(WithBackend(backend), WithCache(NewCache(100, time.Hour, time.Minute)))
+
+	lookup := func(ip string) Result {
+		t.Helper()
+		resp, err := c.Lookup(context.Background(), []string{ip})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		r, ok := resp.Get(ip)
+		if !ok {
+			t.Fatalf("expected a result for %s, got %+v", ip, resp)
+		}
+		return *r
+	}
+
+	if r := lookup("1.0.0.200"); r.ASN != 64501 || !r.SyntheticPrefix {
+		t.Errorf("expected AS64501 with a synthetic prefix, got %+v", r)
+	}
+
+	if r := lookup("1.0.0.5"); r.ASN != 64500 || r.Cached || r.SyntheticPrefix {
+		t.Errorf("expected a queried AS64500 result, got %+v", r)
+	}
+
+	if r := lookup("1.0.0.6"); r.ASN != 64500 || !r.Cached {
+		t.Errorf("expected a cached AS64500 result from the exact prefix, got %+v", r)
+	}
+
+	if r := lookup("1.0.0.200"); r.ASN != 64501 || !r.Cached {
+		t.Errorf("expected the synthetic result cached for its own IP, got %+v", r)
+	}
+
+	if len(backend.queries) != 2 {
+		t.Errorf("expected 2 queries, got %v", backend.queries)
+	}
+}
@@ -0,0 +1,318 @@
+package cymruasn
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// cacheFileVersion is the version of the on-disk cache format.
+const cacheFileVersion = 1
+
+// ErrCacheVersion is returned when loading a cache file written in an
+// unsupported format.
+var ErrCacheVersion = errors.New("unsupported cache file version")
+
+// CacheEntry is a cached result as saved to disk.
+type CacheEntry struct {
+	// Prefix is the announced prefix the result applies to, or a
+	// single-address prefix for a negative entry.
+	Prefix   string
+	Negative bool
+	// Verbose is set when Result carries the registry and allocation
+	// date, as looked up by a client with WithVerbose.
+	Verbose bool
+	Expires time.Time
+	Result  Result
+}
+
+// CacheStats describes the contents of a cache.
+type CacheStats struct {
+	Entries  int
+	Negative int
+	Expired  int
+}
+
+// cacheFile is the on-disk cache format. Entries are ordered from most to
+// least recently used.
+type cacheFile struct {
+	Version int           `json:"version"`
+	Entries []cacheRecord `json:"entries"`
+}
+
+// cacheRecord is one entry of the on-disk cache format.
+type cacheRecord struct {
+	Prefix      string    `json:"prefix"`
+	Negative    bool      `json:"negative,omitempty"`
+	Verbose     bool      `json:"verbose,omitempty"`
+	Expires     time.Time `json:"expires"`
+	ASN         int       `json:"asn"`
+	ASNs        []uint32  `json:"asns,omitempty"`
+	BGPPrefix   string    `json:"bgp_prefix"`
+	Synthetic   bool      `json:"synthetic_prefix,omitempty"`
+	CountryCode string    `json:"country_code"`
+	ASName      string    `json:"as_name"`
+	Registry    string    `json:"registry,omitempty"`
+	Allocated   time.Time `json:"allocated,omitzero"`
+}
+
+// Entries returns the entries in the cache, from most to least recently
+// used, including expired entries not yet removed.
+func (c *Cache) Entries() []CacheEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entries := make([]CacheEntry, 0, c.lru.Len())
+	for el := c.lru.Front(); el != nil; el = el.Next() {
+		e := el.Value.(*cacheEntry)
+		entries = append(entries, CacheEntry{
+			Prefix:   e.key.String(),
+			Negative: e.negative,
+			Verbose:  e.verbose,
+			Expires:  e.expires,
+			Result:   e.result,
+		})
+	}
+
+	return entries
+}
+
+// Stats returns the number of entries in the cache, how many are negative
+// and how many have expired.
+func (c *Cache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.clock.Now()
+	stats := CacheStats{Entries: c.lru.Len()}
+
+	for el := c.lru.Front(); el != nil; el = el.Next() {
+		e := el.Value.(*cacheEntry)
+		if e.negative {
+			stats.Negative++
+		}
+		if now.After(e.expires) {
+			stats.Expired++
+		}
+	}
+
+	return stats
+}
+
+// Prune removes expired entries and returns how many were removed.
+func (c *Cache) Prune() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.clock.Now()
+	removed := 0
+
+	for el := c.lru.Front(); el != nil; {
+		next := el.Next()
+		if now.After(el.Value.(*cacheEntry).expires) {
+			c.removeElement(el)
+			removed++
+		}
+		el = next
+	}
+
+	return removed
+}
+
+// Load adds the unexpired entries saved in the file at path to the cache,
+// keeping existing entries for the same prefix. A missing file is not an
+// error. The file is locked while it is read so that a concurrent Save
+// does not interleave with it.
+func (c *Cache) Load(path string) error {
+	unlock, err := lockFile(path, false)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	records, err := readCacheFile(path)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.merge(records)
+
+	return nil
+}
+
+// Save writes the unexpired entries of the cache to the file at path,
+// together with any unexpired entries saved there by other processes since
+// it was loaded, as room allows. The file is replaced atomically, so a
+// crash leaves either the old or the new file, and is locked so that
+// concurrent saves do not lose each other's entries.
+func (c *Cache) Save(path string) error {
+	unlock, err := lockFile(path, true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	records, err := readCacheFile(path)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.merge(records)
+	file := cacheFile{Version: cacheFileVersion, Entries: c.records()}
+	c.mu.Unlock()
+
+	data, err := json.Marshal(file)
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(path, data)
+}
+
+// records returns the unexpired entries as on-disk records. The caller
+// holds c.mu.
+func (c *Cache) records() []cacheRecord {
+	now := c.clock.Now()
+	records := make([]cacheRecord, 0, c.lru.Len())
+
+	for el := c.lru.Front(); el != nil; el = el.Next() {
+		e := el.Value.(*cacheEntry)
+		if now.After(e.expires) {
+			continue
+		}
+
+		r := e.result
+		records = append(records, cacheRecord{
+			Prefix:      e.key.String(),
+			Negative:    e.negative,
+			Verbose:     e.verbose,
+			Expires:     e.expires,
+			ASN:         r.ASN,
+			ASNs:        r.ASNs,
+			BGPPrefix:   r.BGPPrefix,
+			Synthetic:   r.SyntheticPrefix,
+			CountryCode: r.CountryCode,
+			ASName:      r.ASName,
+			Registry:    r.Registry,
+			Allocated:   r.Allocated,
+		})
+	}
+
+	return records
+}
+
+// merge adds unexpired records for prefixes not already cached as the
+// least recently used entries, while the cache has room. Invalid records
+// are skipped. The caller holds c.mu.
+func (c *Cache) merge(records []cacheRecord) {
+	now := c.clock.Now()
+
+	for _, rec := range records {
+		if c.size > 0 && c.lru.Len() >= c.size {
+			return
+		}
+
+		if now.After(rec.Expires) {
+			continue
+		}
+
+		p, err := netip.ParsePrefix(rec.Prefix)
+		if err != nil {
+			continue
+		}
+		p = normalizePrefix(p)
+
+		if _, ok := c.table.get(p); ok {
+			continue
+		}
+
+		e := &cacheEntry{
+			key:      p,
+			negative: rec.Negative,
+			verbose:  rec.Verbose,
+			expires:  rec.Expires,
+			result: Result{
+				ASN:             rec.ASN,
+				ASNs:            rec.ASNs,
+				BGPPrefix:       rec.BGPPrefix,
+				SyntheticPrefix: rec.Synthetic,
+				CountryCode:     rec.CountryCode,
+				ASName:          rec.ASName,
+				Registry:        rec.Registry,
+				Allocated:       rec.Allocated,
+				MOAS:            len(rec.ASNs) > 1,
+			},
+		}
+
+		c.table.insert(p, c.lru.PushBack(e))
+	}
+}
+
+// readCacheFile reads the records saved in the cache file at path. A
+// missing file yields no records.
+func readCacheFile(path string) ([]cacheRecord, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var file cacheFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	if file.Version != cacheFileVersion {
+		return nil, fmt.Errorf("%s: %w %d", path, ErrCacheVersion, file.Version)
+	}
+
+	return file.Entries, nil
+}
+
+// writeFileAtomic replaces the file at path with data by writing it to a
+// temporary file beside it, syncing it and renaming it into place. The
+// temporary file is path with ".tmp" appended; callers serialize writers
+// with lockFile.
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	// Sync the directory so the rename survives a crash. Not every
+	// platform supports this, so failures are ignored.
+	if dir, err := os.Open(filepath.Dir(path)); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
+
+	return nil
+}
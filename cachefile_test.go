@@ -0,0 +1,124 @@
+package cymruasn
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCacheSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.json")
+
+	cache, clock := newTestCache(10)
+	cache.put(Result{IP: "8.8.8.8", ASN: 15169, ASNs: []uint32{15169}, BGPPrefix: "8.8.8.0/24", CountryCode: "US", ASName: "GOOGLE, US", Registry: "arin"}, true)
+	cache.put(Result{IP: "11.0.0.1", BGPPrefix: "NA"}, false)
+	clock.now = clock.now.Add(-2 * time.Hour)
+	cache.put(Result{IP: "1.1.1.1", ASN: 13335, BGPPrefix: "1.1.1.0/24"}, false)
+	clock.now = clock.now.Add(2 * time.Hour)
+
+	if err := cache.Save(path); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("expected the temporary file to be renamed away, got %v", err)
+	}
+
+	loaded, loadedClock := newTestCache(10)
+	loadedClock.now = clock.now
+
+	if err := loaded.Load(path); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if loaded.Len() != 2 {
+		t.Fatalf("expected 2 unexpired entries, got %d", loaded.Len())
+	}
+
+	r, ok := loaded.get("8.8.8.1", true)
+	if !ok || r.ASN != 15169 || r.ASName != "GOOGLE, US" || r.CountryCode != "US" || r.Registry != "arin" {
+		t.Errorf("unexpected loaded result %+v", r)
+	}
+
+	if r, ok := loaded.get("11.0.0.1", false); !ok || r.ASN != 0 {
+		t.Errorf("expected the negative entry to load, got %+v", r)
+	}
+
+	if _, ok := loaded.get("11.0.0.1", true); ok {
+		t.Error("expected the non-verbose entry to miss for a verbose lookup")
+	}
+
+	stats := loaded.Stats()
+	if stats.Entries != 2 || stats.Negative != 1 || stats.Expired != 0 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestCacheSaveMerges(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.json")
+
+	first, _ := newTestCache(10)
+	first.put(Result{IP: "8.8.8.8", ASN: 15169, BGPPrefix: "8.8.8.0/24"}, false)
+
+	second, _ := newTestCache(10)
+	second.put(Result{IP: "1.1.1.1", ASN: 13335, BGPPrefix: "1.1.1.0/24"}, false)
+
+	if err := first.Save(path); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := second.Save(path); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	loaded, _ := newTestCache(10)
+	if err := loaded.Load(path); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if loaded.Len() != 2 {
+		t.Errorf("expected entries from both saves, got %d", loaded.Len())
+	}
+}
+
+func TestCacheLoadMissing(t *testing.T) {
+	cache, _ := newTestCache(10)
+
+	if err := cache.Load(filepath.Join(t.TempDir(), "missing.json")); err != nil {
+		t.Errorf("expected a missing file to load as empty, got %v", err)
+	}
+}
+
+func TestCacheLoadVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.json")
+	if err := os.WriteFile(path, []byte(`{"version":99,"entries":[]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cache, _ := newTestCache(10)
+	if err := cache.Load(path); !errors.Is(err, ErrCacheVersion) {
+		t.Errorf("expected %v, got %v", ErrCacheVersion, err)
+	}
+}
+
+func TestCachePrune(t *testing.T) {
+	cache, clock := newTestCache(10)
+	cache.put(Result{IP: "8.8.8.8", ASN: 15169, BGPPrefix: "8.8.8.0/24"}, false)
+	cache.put(Result{IP: "11.0.0.1", BGPPrefix: "NA"}, false)
+
+	clock.now = clock.now.Add(10 * time.Minute)
+
+	if stats := cache.Stats(); stats.Expired != 1 {
+		t.Errorf("expected 1 expired entry, got %+v", stats)
+	}
+
+	if removed := cache.Prune(); removed != 1 {
+		t.Errorf("expected 1 entry pruned, got %d", removed)
+	}
+
+	entries := cache.Entries()
+	if len(entries) != 1 || entries[0].Prefix != "8.8.8.0/24" {
+		t.Errorf("unexpected entries after prune %+v", entries)
+	}
+}
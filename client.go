@@ -3,9 +3,11 @@ package cymruasn
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net"
+	"slices"
 	"strings"
 	"time"
 )
@@ -13,47 +15,112 @@ import (
 // NewClient creates a new ASN lookup client with the given options.
 func NewClient(opts ...Option) *Client {
 	c := &Client{
-		server:  DefaultServer,
-		port:    DefaultPort,
-		timeout: DefaultTimeout,
+		server:     DefaultServer,
+		peerServer: DefaultPeerServer,
+		port:       DefaultPort,
+		timeout:    DefaultTimeout,
+
+		batchSize:     DefaultBatchSize,
+		concurrency:   DefaultConcurrency,
+		flushInterval: DefaultFlushInterval,
+		clock:         realClock{},
+		cooldown:      DefaultServerCooldown,
+
+		specialPurposeCheck: true,
 	}
 
 	for _, opt := range opts {
 		opt(c)
 	}
 
+	if len(c.serverAddrs) == 0 {
+		c.serverAddrs = []string{c.server}
+	}
+	c.endpoints = newEndpoints(c.serverAddrs, c.port)
+
+	if c.backend == nil {
+		c.backend = whoisBackend{client: c}
+	}
+
 	return c
 }
 
 // Lookup performs a bulk ASN lookup for the given IP addresses.
 // It returns a Response containing successful results and any lookup errors.
-// The function returns a non-nil error only for connection-level failures.
+// Each distinct IP is queried once, however often and in whatever form it
+// appears; Response.Aligned maps the results back onto the input. IPs
+// already being queried by a concurrent Lookup wait for that query's
+// answer instead of being queried again.
+// Large inputs are split into batches; the IPs of a batch that fails are
+// reported in Errors with the batch error. The function returns a non-nil
+// error only when every batch failed with a connection-level failure,
+// counting the batches of a shared query as the caller's own.
 func (c *Client) Lookup(ctx context.Context, ips []string) (*Response, error) {
 	if len(ips) == 0 {
 		return &Response{}, nil
 	}
 
 	validIPs, invalidErrs := c.validateIPs(ips)
+	queryIPs, localResults := c.classifyIPs(dedupeIPs(validIPs))
+	queryIPs, cachedResults := c.fromCache(queryIPs)
 
-	if len(validIPs) == 0 {
-		return &Response{Errors: invalidErrs}, nil
+	resp := &Response{
+		Results: append(localResults, cachedResults...),
+		Errors:  invalidErrs,
 	}
 
-	request := c.buildRequest(validIPs)
+	if len(queryIPs) > 0 {
+		batches, err := c.queryShared(ctx, queryIPs)
+		if err != nil {
+			return nil, err
+		}
 
-	results, parseErrs, err := c.query(ctx, request)
-	if err != nil {
-		return nil, err
+		resp.Results = append(resp.Results, batches.results...)
+		resp.Errors = append(resp.Errors, batches.errs...)
+		resp.ParseErrors = batches.parseErrs
+		resp.Attempts = batches.attempts
+		resp.Servers = serversOf(resp.Results)
 	}
 
-	lookupErrs := c.matchResultsToIPs(validIPs, results)
-	allErrors := append(invalidErrs, lookupErrs...)
+	c.flagBogons(resp)
+	resp.align(ips)
+
+	return resp, nil
+}
+
+// dedupeIPs returns the IPs with later duplicates of the same canonical IP
+// removed.
+func dedupeIPs(ips []string) []string {
+	seen := make(map[string]bool, len(ips))
+	var unique []string
+
+	for _, ip := range ips {
+		key := canonicalIP(ip)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		unique = append(unique, ip)
+	}
+
+	return unique
+}
+
+// align fills in ByIP and Aligned for the given Lookup input. It must be
+// called once Results is complete, since both point into it.
+func (r *Response) align(ips []string) {
+	r.ByIP = make(map[string]*Result, len(r.Results))
+	for i := range r.Results {
+		key := canonicalIP(r.Results[i].IP)
+		if _, ok := r.ByIP[key]; !ok {
+			r.ByIP[key] = &r.Results[i]
+		}
+	}
 
-	return &Response{
-		Results:     results,
-		Errors:      allErrors,
-		ParseErrors: parseErrs,
-	}, nil
+	r.Aligned = make([]*Result, len(ips))
+	for i, ip := range ips {
+		r.Aligned[i], _ = r.Get(ip)
+	}
 }
 
 // validateIPs checks each IP and returns valid IPs and errors for invalid ones.
@@ -91,8 +158,12 @@ func (c *Client) buildRequest(ips []string) []byte {
 	var buf bytes.Buffer
 
 	buf.WriteString("begin\n")
-	buf.WriteString("prefix\n")
-	buf.WriteString("countrycode\n")
+	if c.verbose {
+		buf.WriteString("verbose\n")
+	} else {
+		buf.WriteString("prefix\n")
+		buf.WriteString("countrycode\n")
+	}
 
 	for _, ip := range ips {
 		buf.WriteString(ip)
@@ -104,53 +175,181 @@ func (c *Client) buildRequest(ips []string) []byte {
 	return buf.Bytes()
 }
 
+// whoisBackend is the default Backend. It queries the bulk whois server
+// configured on the client.
+type whoisBackend struct {
+	client *Client
+}
+
+// Query sends the IPs to the whois servers in a single bulk session.
+func (b whoisBackend) Query(ctx context.Context, ips []string) ([]Result, []ParseError, error) {
+	return b.client.query(ctx, b.client.buildRequest(ips))
+}
+
 // MaxResponseSize is the maximum allowed response size (10MB).
 const MaxResponseSize = 10 * 1024 * 1024
 
-// query sends the request to the whois server and returns parsed results.
+// ErrResponseTooLarge is returned when a response exceeds MaxResponseSize.
+var ErrResponseTooLarge = fmt.Errorf("response exceeded maximum size of %d bytes", MaxResponseSize)
+
+// query sends the request to the whois servers and returns parsed results,
+// tagged with the server that answered.
 func (c *Client) query(ctx context.Context, request []byte) ([]Result, []ParseError, error) {
-	addr := net.JoinHostPort(c.server, c.port)
+	response, server, err := c.fetchWhois(ctx, request)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	results, parseErrs, err := parseResponse(response)
+	for i := range results {
+		results[i].Server = server
+	}
+
+	return results, parseErrs, err
+}
+
+// fetch sends the request to the whois server at addr, given as host:port,
+// and returns the raw response.
+func (c *Client) fetch(ctx context.Context, addr string, request []byte) ([]byte, error) {
+	conn, err := c.send(ctx, addr, request)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
 
+	response, err := io.ReadAll(&maxSizeReader{r: conn})
+	if errors.Is(err, ErrResponseTooLarge) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	return response, nil
+}
+
+// send connects to the whois server at addr, given as host:port, and sends
+// the request. The caller reads the response from the returned connection
+// and closes it.
+func (c *Client) send(ctx context.Context, addr string, request []byte) (net.Conn, error) {
 	dialer := &net.Dialer{
 		Timeout: c.timeout,
 	}
 
 	conn, err := dialer.DialContext(ctx, "tcp", addr)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to connect to %s: %w", addr, err)
+		return nil, fmt.Errorf("failed to connect to %s: %w", addr, err)
 	}
-	defer conn.Close()
 
 	deadline, ok := ctx.Deadline()
 	if !ok {
 		deadline = time.Now().Add(c.timeout)
 	}
 	if setErr := conn.SetDeadline(deadline); setErr != nil {
-		return nil, nil, fmt.Errorf("failed to set deadline: %w", setErr)
+		conn.Close()
+		return nil, fmt.Errorf("failed to set deadline: %w", setErr)
 	}
 
-	_, err = io.Copy(conn, bytes.NewReader(request))
+	// Cancelling ctx interrupts reads and writes in progress.
+	watched := &ctxConn{Conn: conn}
+	watched.stop = context.AfterFunc(ctx, func() {
+		conn.SetDeadline(time.Now())
+	})
+
+	_, err = io.Copy(watched, bytes.NewReader(request))
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to send request: %w", err)
+		watched.Close()
+		return nil, fmt.Errorf("failed to send request: %w", err)
 	}
 
-	limitedReader := io.LimitReader(conn, MaxResponseSize+1)
-	response, err := io.ReadAll(limitedReader)
-	if err != nil {
-		return nil, nil, fmt.Errorf("failed to read response: %w", err)
+	return watched, nil
+}
+
+// ctxConn is a connection whose deadline is cut short when a context is
+// done. Closing it stops watching the context.
+type ctxConn struct {
+	net.Conn
+	stop func() bool
+}
+
+func (c *ctxConn) Close() error {
+	c.stop()
+	return c.Conn.Close()
+}
+
+// maxSizeReader reads from r and fails with ErrResponseTooLarge once more
+// than MaxResponseSize bytes have been read.
+type maxSizeReader struct {
+	r    io.Reader
+	read int64
+	last byte
+}
+
+func (m *maxSizeReader) Read(p []byte) (int, error) {
+	n, err := m.r.Read(p)
+	m.read += int64(n)
+	if n > 0 {
+		m.last = p[n-1]
 	}
-	if len(response) > MaxResponseSize {
-		return nil, nil, fmt.Errorf("response exceeded maximum size of %d bytes", MaxResponseSize)
+	if m.read > MaxResponseSize {
+		return n, ErrResponseTooLarge
 	}
+	return n, err
+}
 
-	return parseResponse(response)
+// matchResultsToIPs merges duplicate results for the same IP and checks
+// which requested IPs are missing from results.
+func (c *Client) matchResultsToIPs(requestedIPs []string, results []Result) ([]Result, []LookupError) {
+	merged := mergeResults(results)
+
+	returned := make([]string, len(merged))
+	for i, r := range merged {
+		returned[i] = r.IP
+	}
+
+	return merged, missingIPs(requestedIPs, returned)
 }
 
-// matchResultsToIPs checks which requested IPs are missing from results.
-func (c *Client) matchResultsToIPs(requestedIPs []string, results []Result) []LookupError {
-	resultMap := make(map[string]bool)
+// mergeResults combines results that refer to the same IP, as returned when
+// a prefix is announced by several origins, into a single result carrying
+// every origin ASN. The order of first appearance is preserved.
+func mergeResults(results []Result) []Result {
+	var merged []Result
+	index := make(map[string]int)
+
 	for _, r := range results {
-		resultMap[canonicalIP(r.IP)] = true
+		key := canonicalIP(r.IP)
+
+		i, ok := index[key]
+		if !ok {
+			index[key] = len(merged)
+			merged = append(merged, r)
+			continue
+		}
+
+		m := &merged[i]
+		if len(m.ASNs) == 0 {
+			*m = r
+			continue
+		}
+
+		for _, asn := range r.ASNs {
+			if !slices.Contains(m.ASNs, asn) {
+				m.ASNs = append(m.ASNs, asn)
+			}
+		}
+		m.MOAS = len(m.ASNs) > 1
+	}
+
+	return merged
+}
+
+// missingIPs returns an error for each requested IP that does not appear in
+// the returned IPs.
+func missingIPs(requestedIPs []string, returnedIPs []string) []LookupError {
+	resultMap := make(map[string]bool)
+	for _, ip := range returnedIPs {
+		resultMap[canonicalIP(ip)] = true
 	}
 
 	var errs []LookupError
@@ -158,7 +357,7 @@ func (c *Client) matchResultsToIPs(requestedIPs []string, results []Result) []Lo
 		if !resultMap[canonicalIP(ip)] {
 			errs = append(errs, LookupError{
 				IP:  ip,
-				Err: fmt.Errorf("no result returned for IP: %s", ip),
+				Err: fmt.Errorf("%w: %s", ErrNoResult, ip),
 			})
 		}
 	}
@@ -4,10 +4,56 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"slices"
 	"testing"
 	"time"
 )
 
+// startMockServer starts a whois server on a local port that reads one
+// request and replies with response. It returns the server host and port.
+func startMockServer(t *testing.T, response string) (string, string) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to create listener: %v", err)
+	}
+	t.Cleanup(func() {
+		if closeErr := listener.Close(); closeErr != nil {
+			t.Logf("failed to close listener: %v", closeErr)
+		}
+	})
+
+	go func() {
+		for {
+			conn, acceptErr := listener.Accept()
+			if acceptErr != nil {
+				return
+			}
+
+			go func() {
+				defer func() {
+					if closeErr := conn.Close(); closeErr != nil {
+						t.Logf("failed to close connection: %v", closeErr)
+					}
+				}()
+
+				buf := make([]byte, 64*1024)
+				if _, readErr := conn.Read(buf); readErr != nil {
+					return
+				}
+
+				if _, writeErr := conn.Write([]byte(response)); writeErr != nil {
+					return
+				}
+			}()
+		}
+	}()
+
+	addr := listener.Addr().(*net.TCPAddr)
+	return "127.0.0.1", fmt.Sprintf("%d", addr.Port)
+}
+
 func TestNewClient(t *testing.T) {
 	t.Run("default values", func(t *testing.T) {
 		c := NewClient()
@@ -20,6 +66,9 @@ func TestNewClient(t *testing.T) {
 		if c.timeout != DefaultTimeout {
 			t.Errorf("expected timeout %v, got %v", DefaultTimeout, c.timeout)
 		}
+		if _, ok := c.backend.(whoisBackend); !ok {
+			t.Errorf("expected default whois backend, got %T", c.backend)
+		}
 	})
 
 	t.Run("with options", func(t *testing.T) {
@@ -105,6 +154,17 @@ func TestBuildRequest(t *testing.T) {
 	}
 }
 
+func TestBuildRequestVerbose(t *testing.T) {
+	c := NewClient(WithVerbose(true))
+	ips := []string{"8.8.8.8", "1.1.1.1"}
+	request := c.buildRequest(ips)
+
+	expected := "begin\nverbose\n8.8.8.8\n1.1.1.1\nend\n"
+	if string(request) != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, string(request))
+	}
+}
+
 func TestLookupEmptyList(t *testing.T) {
 	c := NewClient()
 	resp, err := c.Lookup(context.Background(), []string{})
@@ -201,6 +261,119 @@ func TestLookupWithMockServer(t *testing.T) {
 	}
 }
 
+func TestMergeResults(t *testing.T) {
+	results := []Result{
+		{IP: "192.0.2.10", ASN: 64500, ASNs: []uint32{64500}, BGPPrefix: "192.0.2.0/24"},
+		{IP: "8.8.8.8", ASN: 15169, ASNs: []uint32{15169}, BGPPrefix: "8.8.8.0/24"},
+		{IP: "192.0.2.10", ASN: 64501, ASNs: []uint32{64501}, BGPPrefix: "192.0.2.0/24"},
+		{IP: "192.0.2.10", ASN: 64500, ASNs: []uint32{64500}, BGPPrefix: "192.0.2.0/24"},
+		{IP: "2001:db8::0001", BGPPrefix: "NA"},
+		{IP: "2001:db8::1", ASN: 64502, ASNs: []uint32{64502}, BGPPrefix: "2001:db8::/32"},
+	}
+
+	merged := mergeResults(results)
+
+	if len(merged) != 3 {
+		t.Fatalf("expected 3 merged results, got %d", len(merged))
+	}
+
+	if !slices.Equal(merged[0].ASNs, []uint32{64500, 64501}) {
+		t.Errorf("expected ASNs [64500 64501], got %v", merged[0].ASNs)
+	}
+	if merged[0].ASN != 64500 {
+		t.Errorf("expected ASN 64500, got %d", merged[0].ASN)
+	}
+	if !merged[0].MOAS {
+		t.Error("expected MOAS to be set")
+	}
+
+	if merged[1].MOAS {
+		t.Error("expected MOAS to be unset for single origin")
+	}
+
+	if merged[2].ASN != 64502 || merged[2].BGPPrefix != "2001:db8::/32" {
+		t.Errorf("expected NA result to be replaced, got %+v", merged[2])
+	}
+}
+
+func TestLookupMultipleOrigins(t *testing.T) {
+	mockResponse := `Bulk mode; whois.cymru.com [2024-01-15 12:00:00 +0000]
+64500   | 192.0.2.10       | 192.0.2.0/24     | ZZ | EXAMPLE-A
+64501   | 192.0.2.10       | 192.0.2.0/24     | ZZ | EXAMPLE-B
+`
+	server, port := startMockServer(t, mockResponse)
+
+	c := NewClient(
+		WithServer(server),
+		WithPort(port),
+		WithTimeout(5*time.Second),
+		WithSpecialPurposeCheck(false),
+	)
+
+	resp, err := c.Lookup(context.Background(), []string{"192.0.2.10"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(resp.Results) != 1 {
+		t.Fatalf("expected 1 result, got %d", len(resp.Results))
+	}
+
+	if !resp.Results[0].MOAS {
+		t.Error("expected MOAS to be set")
+	}
+
+	if !slices.Equal(resp.Results[0].ASNs, []uint32{64500, 64501}) {
+		t.Errorf("expected ASNs [64500 64501], got %v", resp.Results[0].ASNs)
+	}
+}
+
+// stubBackend answers queries from a fixed set of results.
+type stubBackend struct {
+	results map[string]Result
+	queries [][]string
+}
+
+func (s *stubBackend) Query(_ context.Context, ips []string) ([]Result, []ParseError, error) {
+	s.queries = append(s.queries, ips)
+
+	var results []Result
+	for _, ip := range ips {
+		if r, ok := s.results[ip]; ok {
+			r.IP = ip
+			results = append(results, r)
+		}
+	}
+	return results, nil, nil
+}
+
+func TestLookupWithBackend(t *testing.T) {
+	backend := &stubBackend{
+		results: map[string]Result{
+			"8.8.8.8": {ASN: 15169, BGPPrefix: "8.8.8.0/24"},
+		},
+	}
+
+	c := NewClient(WithBackend(backend), WithSpecialPurposeCheck(false))
+
+	resp, err := c.Lookup(context.Background(), []string{"8.8.8.8", "192.0.2.1", "bogus"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(backend.queries) != 1 || len(backend.queries[0]) != 2 {
+		t.Errorf("expected one query for 2 valid IPs, got %v", backend.queries)
+	}
+
+	if len(resp.Results) != 1 || resp.Results[0].ASN != 15169 {
+		t.Errorf("expected 1 result for AS15169, got %+v", resp.Results)
+	}
+
+	if len(resp.Errors) != 2 {
+		t.Errorf("expected 2 errors, got %d", len(resp.Errors))
+	}
+}
+
 func TestLookupContextCancellation(t *testing.T) {
 	listener, err := net.Listen("tcp", "127.0.0.1:0")
 	if err != nil {
@@ -254,3 +427,62 @@ func TestLookupConnectionFailure(t *testing.T) {
 		t.Error("expected connection error, got nil")
 	}
 }
+
+func TestLookupDeduplicates(t *testing.T) {
+	backend := &stubBackend{
+		results: map[string]Result{
+			"8.8.8.8":              {ASN: 15169, BGPPrefix: "8.8.8.0/24"},
+			"2001:4860:4860::8888": {ASN: 15169, BGPPrefix: "2001:4860::/32"},
+		},
+	}
+
+	c := NewClient(WithBackend(backend))
+
+	ips := []string{
+		"8.8.8.8",
+		"2001:4860:4860::8888",
+		"bogus",
+		" 8.8.8.8 ",
+		"2001:4860:4860:0:0:0:0:8888",
+		"9.9.9.9",
+		"2001:4860:4860::8888",
+	}
+
+	resp, err := c.Lookup(context.Background(), ips)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(backend.queries) != 1 || len(backend.queries[0]) != 3 {
+		t.Errorf("expected one query for 3 distinct IPs, got %v", backend.queries)
+	}
+
+	if len(resp.Results) != 2 {
+		t.Errorf("expected 2 results, got %d", len(resp.Results))
+	}
+
+	if len(resp.Aligned) != len(ips) {
+		t.Fatalf("expected %d aligned results, got %d", len(ips), len(resp.Aligned))
+	}
+
+	want := []string{"8.8.8.8", "2001:4860:4860::8888", "", "8.8.8.8", "2001:4860:4860::8888", "", "2001:4860:4860::8888"}
+	for i, w := range want {
+		got := resp.Aligned[i]
+		if w == "" {
+			if got != nil {
+				t.Errorf("aligned %d = %+v, want nil", i, got)
+			}
+			continue
+		}
+		if got == nil || got.IP != w {
+			t.Errorf("aligned %d = %+v, want result for %s", i, got, w)
+		}
+	}
+
+	if r, ok := resp.Get("2001:4860:4860:0::8888"); !ok || r.ASN != 15169 {
+		t.Errorf("Get returned %+v, %v", r, ok)
+	}
+	if _, ok := resp.ByIP["8.8.8.8"]; !ok {
+		t.Error("expected ByIP entry for 8.8.8.8")
+	}
+}
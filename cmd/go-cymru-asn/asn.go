@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	cymruasn "github.com/superfrink/go-cymru-asn"
+)
+
+// runASN looks up AS descriptions for the ASNs given as arguments or on
+// stdin, prints the results and exits.
+func runASN(client *cymruasn.Client, args []string) {
+	if len(args) == 0 {
+		args = readFromStdin()
+	}
+
+	if len(args) == 0 {
+		usage()
+	}
+
+	var asns []uint32
+	invalid := 0
+
+	for _, arg := range args {
+		asn, err := cymruasn.ParseASN(arg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			invalid++
+			continue
+		}
+		asns = append(asns, asn)
+	}
+
+	resp, err := client.LookupASNs(context.Background(), asns)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(2)
+	}
+
+	for _, r := range resp.Results {
+		fmt.Printf("%d\t%s\t%s\t%s\t%s\n", r.ASN, r.CountryCode, r.Registry, formatDate(r.Allocated), r.ASName)
+	}
+
+	for _, e := range resp.Errors {
+		fmt.Fprintf(os.Stderr, "error: AS%d: %v\n", e.ASN, e.Err)
+	}
+
+	os.Exit(exitStatus(len(resp.Errors)+invalid, len(resp.Results)))
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	cymruasn "github.com/superfrink/go-cymru-asn"
+)
+
+// runCache inspects, prunes or exports the cache file at path and exits.
+func runCache(cache *cymruasn.Cache, path string, args []string) {
+	if cache == nil || len(args) != 1 {
+		usage()
+	}
+
+	switch args[0] {
+	case "stats":
+		stats := cache.Stats()
+		fmt.Printf("entries\t%d\n", stats.Entries)
+		fmt.Printf("negative\t%d\n", stats.Negative)
+		fmt.Printf("expired\t%d\n", stats.Expired)
+
+	case "prune":
+		removed := cache.Prune()
+		if err := cache.Save(path); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(2)
+		}
+		fmt.Printf("pruned\t%d\n", removed)
+
+	case "export":
+		for _, e := range cache.Entries() {
+			r := e.Result
+			asns := formatASNs(r)
+			if e.Negative {
+				asns = "NA"
+			}
+			fields := []string{e.Prefix, e.Expires.UTC().Format(time.RFC3339), asns, r.BGPPrefix, r.CountryCode, r.ASName}
+			fmt.Println(strings.Join(fields, "\t"))
+		}
+
+	default:
+		usage()
+	}
+
+	os.Exit(0)
+}
+
+// saveCache saves the cache to path, reporting any failure.
+func saveCache(cache *cymruasn.Cache, path string) {
+	if err := cache.Save(path); err != nil {
+		fmt.Fprintf(os.Stderr, "error: saving cache: %v\n", err)
+	}
+}
+
+// exitFuncs run before the process exits through exit.
+var exitFuncs []func()
+
+// exit runs the registered exit functions and exits with the given status.
+func exit(code int) {
+	for _, f := range exitFuncs {
+		f()
+	}
+	os.Exit(code)
+}
@@ -3,70 +3,273 @@ package main
 import (
 	"bufio"
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	cymruasn "github.com/superfrink/go-cymru-asn"
 )
 
 func main() {
-	sandbox()
-
 	timeout := flag.Duration("timeout", 30*time.Second, "connection timeout")
 	server := flag.String("server", cymruasn.DefaultServer, "whois server address")
+	servers := flag.String("servers", "", "comma-separated whois servers (host or host:port) to fail over between, replacing -server")
+	verbose := flag.Bool("verbose", false, "include registry and allocation date")
+	batchSize := flag.Int("batch-size", cymruasn.DefaultBatchSize, "maximum number of IPs per bulk query")
+	concurrency := flag.Int("concurrency", cymruasn.DefaultConcurrency, "maximum number of bulk queries at once")
+	retries := flag.Int("retries", 0, "retry failed queries up to this many times with backoff")
+	rateQueries := flag.Int("rate-queries", 0, "maximum number of queries per -rate-interval (0 for no limit)")
+	rateIPs := flag.Int("rate-ips", 0, "maximum number of IPs or ASNs per -rate-interval (0 for no limit)")
+	rateInterval := flag.Duration("rate-interval", time.Minute, "interval for -rate-queries and -rate-ips")
+	rateFailFast := flag.Bool("rate-fail-fast", false, "fail instead of waiting when the rate limit is reached")
+	useDNS := flag.Bool("dns", false, "look up IPs through the DNS interface instead of bulk whois")
+	dnsServer := flag.String("dns-server", "", "DNS server address (host:port); implies -dns")
+	iptoasnPath := flag.String("iptoasn", "", "answer IP lookups offline from an iptoasn.com TSV file (plain or gzip)")
+	bogonPaths := flag.String("bogons", "", "comma-separated fullbogons files used to flag bogon IPs")
+	cachePath := flag.String("cache", "", "load and save looked up results in this cache file")
+	cacheSize := flag.Int("cache-size", 1000000, "maximum number of entries in the -cache file")
+	cacheTTL := flag.Duration("cache-ttl", 24*time.Hour, "how long -cache keeps results for announced prefixes")
+	cacheNegativeTTL := flag.Duration("cache-negative-ttl", time.Hour, "how long -cache keeps results for unrouted IPs")
 	flag.Parse()
 
-	ips := flag.Args()
+	opts := []cymruasn.Option{
+		cymruasn.WithTimeout(*timeout),
+		cymruasn.WithServer(*server),
+		cymruasn.WithVerbose(*verbose),
+		cymruasn.WithBatchSize(*batchSize),
+		cymruasn.WithConcurrency(*concurrency),
+	}
 
-	if len(ips) == 0 {
-		ips = readFromStdin()
+	if *servers != "" {
+		opts = append(opts, cymruasn.WithServers(strings.Split(*servers, ",")...))
 	}
 
-	if len(ips) == 0 {
-		fmt.Fprintln(os.Stderr, "usage: go-cymru-asn [-timeout duration] [-server addr] IP [IP ...]")
-		fmt.Fprintln(os.Stderr, "       or pipe IPs via stdin (one per line)")
-		os.Exit(2)
+	if *retries > 0 {
+		policy := cymruasn.DefaultRetryPolicy
+		policy.MaxAttempts = *retries + 1
+		opts = append(opts, cymruasn.WithRetry(policy))
 	}
 
-	client := cymruasn.NewClient(
-		cymruasn.WithTimeout(*timeout),
-		cymruasn.WithServer(*server),
-	)
+	if *rateQueries > 0 || *rateIPs > 0 {
+		opts = append(opts, cymruasn.WithRateLimit(cymruasn.RateLimit{
+			Queries:  *rateQueries,
+			IPs:      *rateIPs,
+			Interval: *rateInterval,
+			FailFast: *rateFailFast,
+		}))
+	}
+
+	if (*useDNS || *dnsServer != "") && *iptoasnPath != "" {
+		fmt.Fprintln(os.Stderr, "error: -dns and -iptoasn cannot be combined")
+		usage()
+	}
+
+	if *useDNS || *dnsServer != "" {
+		var dnsOpts []cymruasn.DNSOption
+		if *dnsServer != "" {
+			dnsOpts = append(dnsOpts, cymruasn.WithDNSServer(*dnsServer))
+		}
+		opts = append(opts, cymruasn.WithDNSBackend(cymruasn.NewDNSBackend(dnsOpts...)))
+	}
+
+	if *iptoasnPath != "" {
+		backend, err := cymruasn.LoadIPtoASN(*iptoasnPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(2)
+		}
+		opts = append(opts, cymruasn.WithBackend(backend))
+	}
+
+	if *bogonPaths != "" {
+		bogons, err := cymruasn.LoadBogons(strings.Split(*bogonPaths, ",")...)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(2)
+		}
+		opts = append(opts, cymruasn.WithBogons(bogons))
+	}
 
-	resp, err := client.Lookup(context.Background(), ips)
+	var cache *cymruasn.Cache
+	if *cachePath != "" {
+		cache = cymruasn.NewCache(*cacheSize, *cacheTTL, *cacheNegativeTTL)
+		if err := cache.Load(*cachePath); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(2)
+		}
+		opts = append(opts, cymruasn.WithCache(cache))
+	}
+
+	// Data files are loaded before the sandbox drops filesystem access.
+	// Only the cache file stays writable.
+	sandbox(*cachePath)
+
+	client := cymruasn.NewClient(opts...)
+
+	args := flag.Args()
+
+	if len(args) > 0 && args[0] == "cache" {
+		runCache(cache, *cachePath, args[1:])
+	}
+
+	if cache != nil {
+		exitFuncs = append(exitFuncs, func() { saveCache(cache, *cachePath) })
+	}
+
+	if len(args) > 0 && args[0] == "asn" {
+		runASN(client, args[1:])
+		return
+	}
+
+	if len(args) > 0 && args[0] == "prefix" {
+		runPrefix(client, args[1:])
+		return
+	}
+
+	if len(args) == 0 {
+		if !stdinIsPipe() {
+			usage()
+		}
+		exit(lookupStdin(client, *verbose, *bogonPaths != ""))
+	}
+
+	resp, err := client.Lookup(context.Background(), args)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "error: %v\n", err)
-		os.Exit(2)
+		exit(2)
 	}
 
 	for _, r := range resp.Results {
-		fmt.Printf("%s\t%d\t%s\t%s\t%s\n", r.IP, r.ASN, r.BGPPrefix, r.CountryCode, r.ASName)
+		printResult(r, *verbose, *bogonPaths != "")
 	}
 
 	for _, e := range resp.Errors {
-		fmt.Fprintf(os.Stderr, "error: %s: %v\n", e.IP, e.Err)
+		printLookupError(e)
 	}
 
-	if len(resp.Errors) > 0 && len(resp.Results) > 0 {
-		os.Exit(1)
+	exit(exitStatus(len(resp.Errors), len(resp.Results)))
+}
+
+// lookupStdin looks up the IPs read from stdin, printing each result as it
+// arrives, and returns the exit status.
+func lookupStdin(client *cymruasn.Client, verbose, bogons bool) int {
+	var results, errs int
+
+	for r, err := range client.LookupReader(context.Background(), os.Stdin) {
+		var lookupErr cymruasn.LookupError
+		var parseErr cymruasn.ParseError
+
+		switch {
+		case err == nil:
+			results++
+			printResult(r, verbose, bogons)
+		case errors.As(err, &lookupErr):
+			errs++
+			printLookupError(lookupErr)
+		case errors.As(err, &parseErr):
+			fmt.Fprintf(os.Stderr, "error: %v: %q\n", parseErr.Err, parseErr.Line)
+		default:
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return 2
+		}
 	}
 
-	if len(resp.Errors) > 0 && len(resp.Results) == 0 {
-		os.Exit(2)
+	return exitStatus(errs, results)
+}
+
+// printResult prints a result as a tab-separated line.
+func printResult(r cymruasn.Result, verbose, bogons bool) {
+	fields := []string{r.IP, formatASNs(r), r.BGPPrefix, r.CountryCode}
+	if verbose {
+		fields = append(fields, r.Registry, formatDate(r.Allocated))
+	}
+	fields = append(fields, r.ASName)
+	if bogons {
+		fields = append(fields, formatBogon(r.Bogon))
 	}
+	fmt.Println(strings.Join(fields, "\t"))
 }
 
-func readFromStdin() []string {
-	var ips []string
+// printLookupError prints a lookup error to stderr.
+func printLookupError(e cymruasn.LookupError) {
+	if e.Bogon != "" {
+		fmt.Fprintf(os.Stderr, "error: %s: %v (%s)\n", e.IP, e.Err, e.Bogon)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "error: %s: %v\n", e.IP, e.Err)
+}
 
+// usage prints the command usage and exits.
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: go-cymru-asn [-timeout duration] [-server addr] [-servers addrs] [-verbose] [-batch-size n] [-concurrency n] [-retries n] [-rate-queries n] [-rate-ips n] [-rate-interval d] [-rate-fail-fast] [-dns] [-dns-server addr] [-iptoasn file] [-bogons files] [-cache file] IP [IP ...]")
+	fmt.Fprintln(os.Stderr, "       go-cymru-asn [-timeout duration] [-server addr] asn ASN [ASN ...]")
+	fmt.Fprintln(os.Stderr, "       go-cymru-asn [-timeout duration] [-server addr] prefix CIDR|START-END [...]")
+	fmt.Fprintln(os.Stderr, "       go-cymru-asn -cache file cache stats|prune|export")
+	fmt.Fprintln(os.Stderr, "       or pipe IPs, ASNs or prefixes via stdin (one per line)")
+	os.Exit(2)
+}
+
+// exitStatus returns 0 when every lookup succeeded, 1 when some failed and
+// 2 when all failed.
+func exitStatus(errors, results int) int {
+	if errors > 0 && results > 0 {
+		return 1
+	}
+
+	if errors > 0 && results == 0 {
+		return 2
+	}
+
+	return 0
+}
+
+// formatASNs formats the origin ASNs of a result, listing every origin
+// separated by spaces for multi-origin prefixes.
+func formatASNs(r cymruasn.Result) string {
+	if len(r.ASNs) == 0 {
+		return strconv.Itoa(r.ASN)
+	}
+
+	asns := make([]string, len(r.ASNs))
+	for i, asn := range r.ASNs {
+		asns[i] = strconv.FormatUint(uint64(asn), 10)
+	}
+	return strings.Join(asns, " ")
+}
+
+// formatBogon formats a bogon reason, using "-" for IPs that are not bogons.
+func formatBogon(reason string) string {
+	if reason == "" {
+		return "-"
+	}
+	return reason
+}
+
+// formatDate formats an allocation date, leaving unknown dates empty.
+func formatDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format("2006-01-02")
+}
+
+// stdinIsPipe reports whether stdin is redirected rather than a terminal.
+func stdinIsPipe() bool {
 	info, err := os.Stdin.Stat()
 	if err != nil {
-		return ips
+		return false
 	}
+	return info.Mode()&os.ModeCharDevice == 0
+}
+
+func readFromStdin() []string {
+	var ips []string
 
-	if info.Mode()&os.ModeCharDevice != 0 {
+	if !stdinIsPipe() {
 		return ips
 	}
 
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	cymruasn "github.com/superfrink/go-cymru-asn"
+)
+
+// runPrefix looks up who routes the prefixes or ranges given as arguments
+// or on stdin, prints the results and exits.
+func runPrefix(client *cymruasn.Client, args []string) {
+	if len(args) == 0 {
+		args = readFromStdin()
+	}
+
+	if len(args) == 0 {
+		usage()
+	}
+
+	resp, err := client.LookupPrefixes(context.Background(), args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		exit(2)
+	}
+
+	for _, p := range resp.Results {
+		r := p.Result
+		fields := []string{p.Input, p.Relation.String(), formatASNs(r), r.BGPPrefix, r.CountryCode, r.ASName}
+		fmt.Println(strings.Join(fields, "\t"))
+	}
+
+	for _, e := range resp.Errors {
+		fmt.Fprintf(os.Stderr, "error: %s: %v\n", e.IP, e.Err)
+	}
+
+	exit(exitStatus(len(resp.Errors), len(resp.Results)))
+}
@@ -9,9 +9,39 @@ import (
 	"golang.org/x/sys/unix"
 )
 
-func sandbox() {
-	if err := unix.Pledge("stdio dns inet", ""); err != nil {
+func sandbox(cachePath string) {
+	if cachePath == "" {
+		pledge("stdio dns inet")
+		return
+	}
+
+	// The cache is saved through a temporary file and guarded by a lock
+	// file beside it. The resolver configuration must stay readable once
+	// everything else is hidden.
+	unveil(cachePath, "rwc")
+	unveil(cachePath+".tmp", "rwc")
+	unveil(cachePath+".lock", "rwc")
+	unveil("/etc/resolv.conf", "r")
+	unveil("/etc/hosts", "r")
+
+	if err := unix.UnveilBlock(); err != nil {
+		fmt.Fprintf(os.Stderr, "unveil: %v\n", err)
+		os.Exit(1)
+	}
+
+	pledge("stdio dns inet rpath wpath cpath flock")
+}
+
+func pledge(promises string) {
+	if err := unix.Pledge(promises, ""); err != nil {
 		fmt.Fprintf(os.Stderr, "pledge: %v\n", err)
 		os.Exit(1)
 	}
 }
+
+func unveil(path, permissions string) {
+	if err := unix.Unveil(path, permissions); err != nil {
+		fmt.Fprintf(os.Stderr, "unveil: %v\n", err)
+		os.Exit(1)
+	}
+}
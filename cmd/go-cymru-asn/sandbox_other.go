@@ -2,4 +2,4 @@
 
 package main
 
-func sandbox() {}
+func sandbox(cachePath string) {}
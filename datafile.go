@@ -0,0 +1,53 @@
+package cymruasn
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+)
+
+// openDataFile opens a local data file, transparently decompressing it when
+// it is gzip-compressed. The caller must close the returned reader.
+func openDataFile(path string) (io.ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := maybeGunzip(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	return &dataFile{Reader: r, file: f}, nil
+}
+
+// maybeGunzip returns a reader that decompresses r when it starts with the
+// gzip magic bytes and passes it through unchanged otherwise.
+func maybeGunzip(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+
+	magic, err := br.Peek(2)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	if len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		return gzip.NewReader(br)
+	}
+
+	return br, nil
+}
+
+// dataFile closes the underlying file of a possibly decompressed reader.
+type dataFile struct {
+	io.Reader
+	file *os.File
+}
+
+func (d *dataFile) Close() error {
+	return d.file.Close()
+}
@@ -0,0 +1,275 @@
+package cymruasn
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidRecord is returned when a line of an RIR delegated statistics
+// file cannot be parsed.
+var ErrInvalidRecord = errors.New("invalid delegated statistics record")
+
+// delegatedDateLayout is the date format of RIR delegated statistics.
+const delegatedDateLayout = "20060102"
+
+// Delegation is a record from an RIR delegated-extended statistics file.
+type Delegation struct {
+	Registry    string
+	CountryCode string
+	// Status is the delegation status, such as "allocated", "assigned",
+	// "available" or "reserved".
+	Status string
+	// Date is the date of the delegation. It is zero when the file does not
+	// record one.
+	Date time.Time
+}
+
+// asnDelegation is a delegated range of ASNs.
+type asnDelegation struct {
+	start, end uint32
+	delegation Delegation
+}
+
+// Delegations indexes the IPv4, IPv6 and ASN records of the RIRs'
+// delegated-*-extended-latest files. Load every file before performing
+// lookups; a Delegations must not be modified concurrently with lookups.
+type Delegations struct {
+	ips  rangeIndex[Delegation]
+	asns []asnDelegation
+}
+
+// LoadDelegations loads one or more delegated-extended files, plain or
+// gzip-compressed.
+func LoadDelegations(paths ...string) (*Delegations, error) {
+	d := &Delegations{}
+
+	for _, path := range paths {
+		if err := d.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	return d, nil
+}
+
+// loadFile adds the records of a single file.
+func (d *Delegations) loadFile(path string) error {
+	f, err := openDataFile(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := d.Read(f); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	return nil
+}
+
+// Read adds the records of a delegated-extended file read from r. Record
+// lines have the form:
+//
+//	registry|cc|type|start|value|date|status[|opaque-id]
+//
+// The version line, summary lines and comments are skipped.
+func (d *Delegations) Read(r io.Reader) error {
+	r, err := maybeGunzip(r)
+	if err != nil {
+		return err
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if err := d.addLine(line); err != nil {
+			return fmt.Errorf("delegated line %d: %w", lineNum, err)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	d.ips.sort()
+	slices.SortFunc(d.asns, func(a, b asnDelegation) int {
+		switch {
+		case a.start < b.start:
+			return -1
+		case a.start > b.start:
+			return 1
+		default:
+			return 0
+		}
+	})
+
+	return nil
+}
+
+// addLine parses a single line and adds it to the index. Version and
+// summary lines are ignored.
+func (d *Delegations) addLine(line string) error {
+	fields := strings.Split(line, "|")
+
+	// Version line: version|registry|serial|records|startdate|enddate|UTCoffset
+	if _, err := strconv.ParseFloat(fields[0], 64); err == nil {
+		return nil
+	}
+
+	// Summary line: registry|*|type|*|count|summary
+	if len(fields) == 6 && fields[5] == "summary" {
+		return nil
+	}
+
+	if len(fields) < 7 {
+		return ErrInvalidRecord
+	}
+
+	delegation := Delegation{
+		Registry:    fields[0],
+		CountryCode: fields[1],
+		Status:      fields[6],
+	}
+
+	if fields[5] != "" && strings.Trim(fields[5], "0") != "" {
+		date, err := time.Parse(delegatedDateLayout, fields[5])
+		if err != nil {
+			return err
+		}
+		delegation.Date = date
+	}
+
+	value, err := strconv.ParseUint(fields[4], 10, 64)
+	if err != nil {
+		return err
+	}
+	if value == 0 {
+		return fmt.Errorf("invalid count: %s", fields[4])
+	}
+
+	switch fields[2] {
+	case "ipv4":
+		start, err := netip.ParseAddr(fields[3])
+		if err != nil || !start.Is4() {
+			return fmt.Errorf("invalid IPv4 address: %s", fields[3])
+		}
+
+		last := uint64(ipv4ToUint32(start)) + value - 1
+		if last > math.MaxUint32 {
+			return fmt.Errorf("IPv4 range overflows: %s+%d", fields[3], value)
+		}
+
+		d.ips.add(start, uint32ToIPv4(uint32(last)), delegation)
+
+	case "ipv6":
+		start, err := netip.ParseAddr(fields[3])
+		if err != nil || !start.Is6() {
+			return fmt.Errorf("invalid IPv6 address: %s", fields[3])
+		}
+
+		prefix, err := start.Prefix(int(min(value, 129)))
+		if err != nil {
+			return err
+		}
+
+		r := prefixRange(prefix)
+		d.ips.add(r.start, r.end, delegation)
+
+	case "asn":
+		start, err := strconv.ParseUint(fields[3], 10, 32)
+		if err != nil {
+			return err
+		}
+
+		last := start + value - 1
+		if last > math.MaxUint32 {
+			return fmt.Errorf("ASN range overflows: %s+%d", fields[3], value)
+		}
+
+		d.asns = append(d.asns, asnDelegation{
+			start:      uint32(start),
+			end:        uint32(last),
+			delegation: delegation,
+		})
+
+	default:
+		return fmt.Errorf("unknown record type: %s", fields[2])
+	}
+
+	return nil
+}
+
+// LookupIP returns the delegation record covering ip.
+func (d *Delegations) LookupIP(ip string) (Delegation, bool) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return Delegation{}, false
+	}
+
+	_, delegation, ok := d.ips.lookup(addr)
+	return delegation, ok
+}
+
+// LookupASN returns the delegation record covering asn.
+func (d *Delegations) LookupASN(asn uint32) (Delegation, bool) {
+	i, _ := slices.BinarySearchFunc(d.asns, asn, func(e asnDelegation, target uint32) int {
+		if e.start <= target {
+			return -1
+		}
+		return 1
+	})
+
+	if i > 0 && d.asns[i-1].end >= asn {
+		return d.asns[i-1].delegation, true
+	}
+
+	return Delegation{}, false
+}
+
+// Enrich sets the Delegation of each result in resp to the record covering
+// its IP. Results without a covering record are left unchanged.
+func (d *Delegations) Enrich(resp *Response) {
+	for i := range resp.Results {
+		if delegation, ok := d.LookupIP(resp.Results[i].IP); ok {
+			resp.Results[i].Delegation = &delegation
+		}
+	}
+}
+
+// EnrichASNs sets the Delegation of each result in resp to the record
+// covering its ASN. Results without a covering record are left unchanged.
+func (d *Delegations) EnrichASNs(resp *ASNResponse) {
+	for i := range resp.Results {
+		if delegation, ok := d.LookupASN(resp.Results[i].ASN); ok {
+			resp.Results[i].Delegation = &delegation
+		}
+	}
+}
+
+// ipv4ToUint32 converts an IPv4 address to an integer.
+func ipv4ToUint32(addr netip.Addr) uint32 {
+	b := addr.As4()
+	return uint32(b[0])<<24 | uint32(b[1])<<16 | uint32(b[2])<<8 | uint32(b[3])
+}
+
+// uint32ToIPv4 converts an integer to an IPv4 address.
+func uint32ToIPv4(n uint32) netip.Addr {
+	return netip.AddrFrom4([4]byte{byte(n >> 24), byte(n >> 16), byte(n >> 8), byte(n)})
+}
@@ -0,0 +1,141 @@
+package cymruasn
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testDelegatedARIN = `2.3|arin|1705000000|5|19700101|20240115|-0500
+arin|*|asn|*|2|summary
+arin|*|ipv4|*|2|summary
+arin|*|ipv6|*|1|summary
+arin|US|asn|15169|1|20000330|assigned|abc123
+arin|US|asn|64496|16|20100101|reserved|
+arin|US|ipv4|8.0.0.0|16777216|19921201|allocated|def456
+arin|US|ipv4|192.0.2.0|256|00000000|reserved|
+arin|US|ipv6|2001:4860::|32|20050314|allocated|ghi789
+`
+
+const testDelegatedAPNIC = `# APNIC delegated-extended
+2|apnic|20240115|1|19830613|20240114|+1000
+apnic|AU|ipv4|1.0.0.0|768|20110811|assigned|A91872ED
+apnic||ipv4|1.0.4.0|256||available|
+`
+
+func TestDelegations(t *testing.T) {
+	dir := t.TempDir()
+	arinPath := filepath.Join(dir, "delegated-arin-extended-latest")
+	apnicPath := filepath.Join(dir, "delegated-apnic-extended-latest")
+
+	if err := os.WriteFile(arinPath, []byte(testDelegatedARIN), 0o644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	if err := os.WriteFile(apnicPath, []byte(testDelegatedAPNIC), 0o644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	d, err := LoadDelegations(arinPath, apnicPath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ipTests := []struct {
+		ip       string
+		registry string
+		cc       string
+		status   string
+		date     time.Time
+		wantOK   bool
+	}{
+		{"8.8.8.8", "arin", "US", "allocated", time.Date(1992, 12, 1, 0, 0, 0, 0, time.UTC), true},
+		{"8.255.255.255", "arin", "US", "allocated", time.Date(1992, 12, 1, 0, 0, 0, 0, time.UTC), true},
+		{"192.0.2.1", "arin", "US", "reserved", time.Time{}, true},
+		{"1.0.2.1", "apnic", "AU", "assigned", time.Date(2011, 8, 11, 0, 0, 0, 0, time.UTC), true},
+		{"1.0.3.0", "", "", "", time.Time{}, false},
+		{"1.0.4.1", "apnic", "", "available", time.Time{}, true},
+		{"2001:4860:4860::8888", "arin", "US", "allocated", time.Date(2005, 3, 14, 0, 0, 0, 0, time.UTC), true},
+		{"9.0.0.0", "", "", "", time.Time{}, false},
+	}
+
+	for _, tt := range ipTests {
+		got, ok := d.LookupIP(tt.ip)
+		if ok != tt.wantOK {
+			t.Errorf("LookupIP(%s) ok = %v, want %v", tt.ip, ok, tt.wantOK)
+			continue
+		}
+		if got.Registry != tt.registry || got.CountryCode != tt.cc || got.Status != tt.status || !got.Date.Equal(tt.date) {
+			t.Errorf("LookupIP(%s) = %+v", tt.ip, got)
+		}
+	}
+
+	asnTests := []struct {
+		asn    uint32
+		status string
+		wantOK bool
+	}{
+		{15169, "assigned", true},
+		{64496, "reserved", true},
+		{64511, "reserved", true},
+		{64512, "", false},
+		{1, "", false},
+	}
+
+	for _, tt := range asnTests {
+		got, ok := d.LookupASN(tt.asn)
+		if ok != tt.wantOK || got.Status != tt.status {
+			t.Errorf("LookupASN(%d) = %+v, %v; want status %q, %v", tt.asn, got, ok, tt.status, tt.wantOK)
+		}
+	}
+}
+
+func TestDelegationsEnrich(t *testing.T) {
+	d := &Delegations{}
+	if err := d.Read(strings.NewReader(testDelegatedARIN)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	resp := &Response{Results: []Result{
+		{IP: "8.8.8.8", ASN: 15169, CountryCode: "US"},
+		{IP: "203.0.113.1"},
+	}}
+	d.Enrich(resp)
+
+	if resp.Results[0].Delegation == nil || resp.Results[0].Delegation.Status != "allocated" {
+		t.Errorf("expected allocated delegation, got %+v", resp.Results[0].Delegation)
+	}
+	if resp.Results[1].Delegation != nil {
+		t.Errorf("expected no delegation, got %+v", resp.Results[1].Delegation)
+	}
+
+	asnResp := &ASNResponse{Results: []ASInfo{{ASN: 15169}, {ASN: 13335}}}
+	d.EnrichASNs(asnResp)
+
+	if asnResp.Results[0].Delegation == nil || asnResp.Results[0].Delegation.Registry != "arin" {
+		t.Errorf("expected arin delegation, got %+v", asnResp.Results[0].Delegation)
+	}
+	if asnResp.Results[1].Delegation != nil {
+		t.Errorf("expected no delegation, got %+v", asnResp.Results[1].Delegation)
+	}
+}
+
+func TestDelegationsInvalid(t *testing.T) {
+	tests := []string{
+		"arin|US|ipv4|8.0.0.0",
+		"arin|US|ipv4|not-an-ip|256|20000101|allocated",
+		"arin|US|ipv4|255.255.255.0|512|20000101|allocated",
+		"arin|US|ipv6|2001:db8::|129|20000101|allocated",
+		"arin|US|asn|15169|0|20000101|assigned",
+		"arin|US|asn|15169|1|2000-01-01|assigned",
+		"arin|US|fqdn|example.com|1|20000101|assigned",
+	}
+
+	for _, input := range tests {
+		d := &Delegations{}
+		if err := d.Read(strings.NewReader(input)); err == nil {
+			t.Errorf("expected error for %q, got nil", input)
+		}
+	}
+}
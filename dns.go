@@ -0,0 +1,333 @@
+package cymruasn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DNS zones used by Team Cymru's DNS interface.
+const (
+	originZone  = "origin.asn.cymru.com"
+	origin6Zone = "origin6.asn.cymru.com"
+	asnZone     = "asn.cymru.com"
+)
+
+// dnsConcurrency is the maximum number of DNS queries in flight for a
+// single batch.
+const dnsConcurrency = 16
+
+// Resolver looks up DNS TXT records. *net.Resolver satisfies this interface.
+type Resolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+// DNSBackend performs lookups against Team Cymru's DNS interface. Each IP
+// costs one TXT query for its origin and one per distinct origin ASN for the
+// AS name, so it suits small numbers of IPs better than bulk whois.
+type DNSBackend struct {
+	resolver Resolver
+}
+
+// DNSOption configures a DNSBackend.
+type DNSOption func(*DNSBackend)
+
+// NewDNSBackend creates a new DNS lookup backend with the given options.
+// By default it uses the system resolver.
+func NewDNSBackend(opts ...DNSOption) *DNSBackend {
+	d := &DNSBackend{
+		resolver: net.DefaultResolver,
+	}
+
+	for _, opt := range opts {
+		opt(d)
+	}
+
+	return d
+}
+
+// WithResolver sets the resolver used for TXT queries.
+func WithResolver(resolver Resolver) DNSOption {
+	return func(d *DNSBackend) {
+		d.resolver = resolver
+	}
+}
+
+// WithDNSServer sends TXT queries to the DNS server at addr (host:port)
+// instead of the system's configured servers.
+func WithDNSServer(addr string) DNSOption {
+	return func(d *DNSBackend) {
+		d.resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, network, addr)
+			},
+		}
+	}
+}
+
+// Query looks up each IP through the DNS interface. IPs without an origin
+// record are omitted from the results. IPs whose lookup failed, other than
+// for a missing record, are reported in IPErrors alongside the results for
+// the others; the error is only the lookup failure itself when every IP
+// failed.
+func (d *DNSBackend) Query(ctx context.Context, ips []string) ([]Result, []ParseError, error) {
+	type answer struct {
+		result    Result
+		found     bool
+		parseErrs []ParseError
+		err       error
+	}
+
+	answers := make([]answer, len(ips))
+	names := &asNameCache{names: make(map[int]string)}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, dnsConcurrency)
+
+	for i, ip := range ips {
+		wg.Add(1)
+		sem <- struct{}{}
+
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			a := &answers[i]
+			a.result, a.found, a.parseErrs, a.err = d.lookupIP(ctx, ip, names)
+		}()
+	}
+
+	wg.Wait()
+
+	var results []Result
+	var parseErrs []ParseError
+	var failed IPErrors
+
+	for i, a := range answers {
+		parseErrs = append(parseErrs, a.parseErrs...)
+		if a.err != nil {
+			failed = append(failed, LookupError{IP: ips[i], Err: a.err})
+			continue
+		}
+		if a.found {
+			results = append(results, a.result)
+		}
+	}
+
+	switch {
+	case len(failed) == 0:
+		return results, parseErrs, nil
+	case len(failed) == len(ips):
+		return nil, parseErrs, failed[0].Err
+	default:
+		return results, parseErrs, failed
+	}
+}
+
+// lookupIP resolves the origin record for a single IP and its AS name.
+func (d *DNSBackend) lookupIP(ctx context.Context, ip string, names *asNameCache) (Result, bool, []ParseError, error) {
+	name, err := originName(ip)
+	if err != nil {
+		return Result{}, false, nil, err
+	}
+
+	txts, err := d.lookupTXT(ctx, name)
+	if err != nil || len(txts) == 0 {
+		return Result{}, false, nil, err
+	}
+
+	var parseErrs []ParseError
+	var best Result
+	found := false
+
+	for _, txt := range txts {
+		r, err := parseOriginTXT(txt)
+		if err != nil {
+			parseErrs = append(parseErrs, ParseError{Line: txt, Err: err})
+			continue
+		}
+
+		if !found {
+			best, found = r, true
+			continue
+		}
+
+		switch comparePrefixLen(r.BGPPrefix, best.BGPPrefix) {
+		case 1:
+			best = r
+		case 0:
+			if r.BGPPrefix == best.BGPPrefix {
+				best = mergeResults([]Result{best, r})[0]
+			}
+		}
+	}
+
+	if !found {
+		return Result{}, false, parseErrs, nil
+	}
+
+	best.IP = ip
+
+	if best.ASN != 0 {
+		asName, err := d.lookupASName(ctx, best.ASN, names)
+		if err != nil {
+			return Result{}, false, nil, err
+		}
+		best.ASName = asName
+	}
+
+	return best, true, parseErrs, nil
+}
+
+// lookupASName returns the AS name for asn, consulting names first.
+func (d *DNSBackend) lookupASName(ctx context.Context, asn int, names *asNameCache) (string, error) {
+	if name, ok := names.get(asn); ok {
+		return name, nil
+	}
+
+	txts, err := d.lookupTXT(ctx, fmt.Sprintf("AS%d.%s", asn, asnZone))
+	if err != nil {
+		return "", err
+	}
+
+	name := ""
+	for _, txt := range txts {
+		info, err := parseASNLine(txt)
+		if err == nil {
+			name = info.ASName
+			break
+		}
+	}
+
+	names.set(asn, name)
+	return name, nil
+}
+
+// lookupTXT performs a TXT query, treating a missing record as an empty
+// answer rather than an error.
+func (d *DNSBackend) lookupTXT(ctx context.Context, name string) ([]string, error) {
+	txts, err := d.resolver.LookupTXT(ctx, name)
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to resolve %s: %w", name, err)
+	}
+	return txts, nil
+}
+
+// asNameCache remembers AS names resolved during a single Query.
+type asNameCache struct {
+	mu    sync.Mutex
+	names map[int]string
+}
+
+func (c *asNameCache) get(asn int) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	name, ok := c.names[asn]
+	return name, ok
+}
+
+func (c *asNameCache) set(asn int, name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.names[asn] = name
+}
+
+// originName returns the DNS name of the origin record for ip: the reversed
+// octets under origin.asn.cymru.com for IPv4, or the reversed nibbles under
+// origin6.asn.cymru.com for IPv6.
+func originName(ip string) (string, error) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return "", fmt.Errorf("invalid IP address: %s", ip)
+	}
+	addr = addr.Unmap().WithZone("")
+
+	var b strings.Builder
+
+	if addr.Is4() {
+		octets := addr.As4()
+		for i := len(octets) - 1; i >= 0; i-- {
+			b.WriteString(strconv.Itoa(int(octets[i])))
+			b.WriteByte('.')
+		}
+		b.WriteString(originZone)
+		return b.String(), nil
+	}
+
+	const hexDigits = "0123456789abcdef"
+	bytes := addr.As16()
+	for i := len(bytes) - 1; i >= 0; i-- {
+		b.WriteByte(hexDigits[bytes[i]&0x0f])
+		b.WriteByte('.')
+		b.WriteByte(hexDigits[bytes[i]>>4])
+		b.WriteByte('.')
+	}
+	b.WriteString(origin6Zone)
+	return b.String(), nil
+}
+
+// parseOriginTXT parses the payload of an origin TXT record.
+// Expected format: AS | BGP Prefix | CC | Registry | Allocated
+func parseOriginTXT(txt string) (Result, error) {
+	parts, err := splitLine(txt)
+	if err != nil {
+		return Result{}, err
+	}
+
+	var result Result
+
+	if err := parseOrigins(&result, parts[0]); err != nil {
+		return Result{}, err
+	}
+
+	result.BGPPrefix = parts[1]
+
+	if len(parts) >= 3 {
+		result.CountryCode = parts[2]
+	}
+
+	if len(parts) >= 4 {
+		result.Registry = parts[3]
+	}
+
+	if len(parts) >= 5 {
+		allocated, err := parseAllocated(parts[4])
+		if err != nil {
+			return Result{}, err
+		}
+		result.Allocated = allocated
+	}
+
+	return result, nil
+}
+
+// comparePrefixLen compares the prefix lengths of two CIDR strings,
+// returning 1 when a is more specific than b, -1 when it is less specific
+// and 0 when they are equal or either cannot be parsed.
+func comparePrefixLen(a, b string) int {
+	pa, errA := netip.ParsePrefix(a)
+	pb, errB := netip.ParsePrefix(b)
+	if errA != nil || errB != nil {
+		return 0
+	}
+
+	switch {
+	case pa.Bits() > pb.Bits():
+		return 1
+	case pa.Bits() < pb.Bits():
+		return -1
+	default:
+		return 0
+	}
+}
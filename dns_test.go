@@ -0,0 +1,369 @@
+package cymruasn
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeResolver answers TXT queries from a fixed map.
+type fakeResolver map[string][]string
+
+func (f fakeResolver) LookupTXT(_ context.Context, name string) ([]string, error) {
+	txts, ok := f[name]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+	return txts, nil
+}
+
+func TestOriginName(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want string
+	}{
+		{"8.8.4.4", "4.4.8.8.origin.asn.cymru.com"},
+		{"192.0.2.1", "1.2.0.192.origin.asn.cymru.com"},
+		{"::ffff:192.0.2.1", "1.2.0.192.origin.asn.cymru.com"},
+		{
+			"2001:4860:4860::8888",
+			"8.8.8.8.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.6.8.4.0.6.8.4.1.0.0.2.origin6.asn.cymru.com",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			got, err := originName(tt.ip)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("originName(%q) = %s, want %s", tt.ip, got, tt.want)
+			}
+		})
+	}
+
+	if _, err := originName("not-an-ip"); err == nil {
+		t.Error("expected error for invalid IP, got nil")
+	}
+}
+
+func TestParseOriginTXT(t *testing.T) {
+	got, err := parseOriginTXT("15169 | 8.8.8.0/24 | US | arin | 1992-12-01")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got.ASN != 15169 {
+		t.Errorf("ASN: got %d, want 15169", got.ASN)
+	}
+	if got.BGPPrefix != "8.8.8.0/24" {
+		t.Errorf("BGPPrefix: got %s, want 8.8.8.0/24", got.BGPPrefix)
+	}
+	if got.CountryCode != "US" {
+		t.Errorf("CountryCode: got %s, want US", got.CountryCode)
+	}
+	if got.Registry != "arin" {
+		t.Errorf("Registry: got %s, want arin", got.Registry)
+	}
+	if !got.Allocated.Equal(time.Date(1992, 12, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Allocated: got %v", got.Allocated)
+	}
+
+	if _, err := parseOriginTXT("garbage"); err == nil {
+		t.Error("expected error for invalid record, got nil")
+	}
+}
+
+func TestDNSBackendQuery(t *testing.T) {
+	resolver := fakeResolver{
+		"8.8.8.8.origin.asn.cymru.com": {
+			"15169 | 8.0.0.0/9 | US | arin | 1992-12-01",
+			"15169 | 8.8.8.0/24 | US | arin | 1992-12-01",
+		},
+		"10.2.0.192.origin.asn.cymru.com": {
+			"64500 | 192.0.2.0/24 | ZZ | other | ",
+			"64501 | 192.0.2.0/24 | ZZ | other | ",
+		},
+		"AS15169.asn.cymru.com": {"15169 | US | arin | 2000-03-30 | GOOGLE, US"},
+		"AS64500.asn.cymru.com": {"64500 | ZZ | other | | EXAMPLE"},
+	}
+
+	d := NewDNSBackend(WithResolver(resolver))
+
+	results, parseErrs, err := d.Query(context.Background(), []string{"8.8.8.8", "192.0.2.10", "203.0.113.1"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(parseErrs) != 0 {
+		t.Errorf("expected 0 parse errors, got %d", len(parseErrs))
+	}
+
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
+	}
+
+	if results[0].BGPPrefix != "8.8.8.0/24" {
+		t.Errorf("expected most specific prefix 8.8.8.0/24, got %s", results[0].BGPPrefix)
+	}
+	if results[0].ASName != "GOOGLE, US" {
+		t.Errorf("expected AS name GOOGLE, US, got %s", results[0].ASName)
+	}
+	if results[0].IP != "8.8.8.8" {
+		t.Errorf("expected IP 8.8.8.8, got %s", results[0].IP)
+	}
+
+	if !results[1].MOAS || !slices.Equal(results[1].ASNs, []uint32{64500, 64501}) {
+		t.Errorf("expected MOAS result with ASNs [64500 64501], got %+v", results[1])
+	}
+}
+
+func TestLookupWithDNSBackend(t *testing.T) {
+	resolver := fakeResolver{
+		"1.1.1.1.origin.asn.cymru.com": {"13335 | 1.1.1.0/24 | AU | apnic | 2011-08-11"},
+		"AS13335.asn.cymru.com":        {"13335 | US | arin | 2010-07-14 | CLOUDFLARENET, US"},
+	}
+
+	c := NewClient(
+		WithDNSBackend(NewDNSBackend(WithResolver(resolver))),
+		WithSpecialPurposeCheck(false),
+	)
+
+	resp, err := c.Lookup(context.Background(), []string{"1.1.1.1", "203.0.113.1"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(resp.Results) != 1 || resp.Results[0].ASN != 13335 {
+		t.Errorf("expected 1 result for AS13335, got %+v", resp.Results)
+	}
+
+	if len(resp.Errors) != 1 || resp.Errors[0].IP != "203.0.113.1" {
+		t.Errorf("expected 1 error for 203.0.113.1, got %+v", resp.Errors)
+	}
+}
+
+func TestLookupWithDNSBackendVerbose(t *testing.T) {
+	resolver := fakeResolver{
+		"1.1.1.1.origin.asn.cymru.com": {"13335 | 1.1.1.0/24 | AU | apnic | 2011-08-11"},
+		"AS13335.asn.cymru.com":        {"13335 | US | arin | 2010-07-14 | CLOUDFLARENET, US"},
+	}
+
+	for _, verbose := range []bool{false, true} {
+		c := NewClient(WithDNSBackend(NewDNSBackend(WithResolver(resolver))), WithVerbose(verbose))
+
+		resp, err := c.Lookup(context.Background(), []string{"1.1.1.1"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(resp.Results) != 1 {
+			t.Fatalf("expected 1 result, got %+v", resp.Results)
+		}
+
+		r := resp.Results[0]
+		if got := r.Registry != "" && !r.Allocated.IsZero(); got != verbose {
+			t.Errorf("verbose %v: got Registry %q and Allocated %v", verbose, r.Registry, r.Allocated)
+		}
+	}
+}
+
+// failingResolver answers TXT queries from records, except that queries for
+// the names in fail time out.
+type failingResolver struct {
+	records fakeResolver
+	fail    []string
+}
+
+func (f failingResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	if slices.Contains(f.fail, name) {
+		return nil, &net.DNSError{Err: "i/o timeout", Name: name, IsTimeout: true}
+	}
+	return f.records.LookupTXT(ctx, name)
+}
+
+func TestDNSBackendQueryPartialFailure(t *testing.T) {
+	resolver := failingResolver{
+		records: fakeResolver{
+			"8.8.8.8.origin.asn.cymru.com": {"15169 | 8.8.8.0/24 | US | arin | 1992-12-01"},
+			"AS15169.asn.cymru.com":        {"15169 | US | arin | 2000-03-30 | GOOGLE, US"},
+			"1.1.1.1.origin.asn.cymru.com": {"13335 | 1.1.1.0/24 | AU | apnic | 2011-08-11"},
+		},
+		fail: []string{"AS13335.asn.cymru.com"},
+	}
+
+	d := NewDNSBackend(WithResolver(resolver))
+
+	results, _, err := d.Query(context.Background(), []string{"8.8.8.8", "1.1.1.1", "bogus"})
+
+	var failed IPErrors
+	if !errors.As(err, &failed) {
+		t.Fatalf("expected IPErrors, got %v", err)
+	}
+	if len(failed) != 2 || failed[0].IP != "1.1.1.1" || failed[1].IP != "bogus" {
+		t.Errorf("expected errors for 1.1.1.1 and bogus, got %+v", failed)
+	}
+
+	if len(results) != 1 || results[0].IP != "8.8.8.8" {
+		t.Errorf("expected the result for 8.8.8.8, got %+v", results)
+	}
+
+	// With every IP failed, the batch fails as a whole.
+	if _, _, err := d.Query(context.Background(), []string{"1.1.1.1"}); err == nil || errors.As(err, &failed) {
+		t.Errorf("expected a plain error, got %v", err)
+	}
+}
+
+func TestLookupWithDNSBackendPartialFailure(t *testing.T) {
+	resolver := failingResolver{
+		records: fakeResolver{
+			"8.8.8.8.origin.asn.cymru.com": {"15169 | 8.8.8.0/24 | US | arin | 1992-12-01"},
+			"AS15169.asn.cymru.com":        {"15169 | US | arin | 2000-03-30 | GOOGLE, US"},
+		},
+		fail: []string{"1.1.1.1.origin.asn.cymru.com"},
+	}
+
+	c := NewClient(WithDNSBackend(NewDNSBackend(WithResolver(resolver))))
+
+	resp, err := c.Lookup(context.Background(), []string{"8.8.8.8", "1.1.1.1"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(resp.Results) != 1 || resp.Results[0].ASN != 15169 {
+		t.Errorf("expected 1 result for AS15169, got %+v", resp.Results)
+	}
+
+	var dnsErr *net.DNSError
+	if len(resp.Errors) != 1 || resp.Errors[0].IP != "1.1.1.1" || !errors.As(resp.Errors[0].Err, &dnsErr) {
+		t.Errorf("expected the resolver error for 1.1.1.1, got %+v", resp.Errors)
+	}
+
+	var streamed []error
+	for _, err := range c.LookupStream(context.Background(), []string{"8.8.8.8", "1.1.1.1"}) {
+		streamed = append(streamed, err)
+	}
+	if len(streamed) != 2 || streamed[0] != nil || !errors.As(streamed[1], &dnsErr) {
+		t.Errorf("expected a result and the resolver error from LookupStream, got %v", streamed)
+	}
+}
+
+// startDNSStub starts a UDP DNS server on a local port that answers TXT
+// queries from records and returns NXDOMAIN for anything else.
+func startDNSStub(t *testing.T, records map[string]string) string {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to create listener: %v", err)
+	}
+	t.Cleanup(func() {
+		if closeErr := conn.Close(); closeErr != nil {
+			t.Logf("failed to close listener: %v", closeErr)
+		}
+	})
+
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, readErr := conn.ReadFrom(buf)
+			if readErr != nil {
+				return
+			}
+
+			reply := dnsStubReply(buf[:n], records)
+			if reply == nil {
+				continue
+			}
+
+			if _, writeErr := conn.WriteTo(reply, addr); writeErr != nil {
+				return
+			}
+		}
+	}()
+
+	return conn.LocalAddr().String()
+}
+
+// dnsStubReply builds the response to a single-question DNS query.
+func dnsStubReply(query []byte, records map[string]string) []byte {
+	if len(query) < 12 {
+		return nil
+	}
+
+	var labels []string
+	off := 12
+	for off < len(query) && query[off] != 0 {
+		n := int(query[off])
+		if off+1+n > len(query) {
+			return nil
+		}
+		labels = append(labels, string(query[off+1:off+1+n]))
+		off += 1 + n
+	}
+	off += 5 // terminating label, type and class
+	if off > len(query) {
+		return nil
+	}
+
+	name := strings.ToLower(strings.Join(labels, "."))
+	qtype := binary.BigEndian.Uint16(query[off-4 : off-2])
+	txt, ok := records[name]
+
+	reply := make([]byte, 12, 512)
+	copy(reply, query[:2])
+	binary.BigEndian.PutUint16(reply[4:], 1)
+
+	switch {
+	case !ok:
+		binary.BigEndian.PutUint16(reply[2:], 0x8183)
+	case qtype != 16:
+		binary.BigEndian.PutUint16(reply[2:], 0x8180)
+	default:
+		binary.BigEndian.PutUint16(reply[2:], 0x8180)
+		binary.BigEndian.PutUint16(reply[6:], 1)
+	}
+
+	reply = append(reply, query[12:off]...)
+
+	if ok && qtype == 16 {
+		reply = append(reply, 0xc0, 0x0c)                // name pointer
+		reply = binary.BigEndian.AppendUint16(reply, 16) // TXT
+		reply = binary.BigEndian.AppendUint16(reply, 1)  // IN
+		reply = binary.BigEndian.AppendUint32(reply, 60) // TTL
+		reply = binary.BigEndian.AppendUint16(reply, uint16(len(txt)+1))
+		reply = append(reply, byte(len(txt)))
+		reply = append(reply, txt...)
+	}
+
+	return reply
+}
+
+func TestDNSBackendWithDNSServer(t *testing.T) {
+	addr := startDNSStub(t, map[string]string{
+		"8.8.8.8.origin.asn.cymru.com": "15169 | 8.8.8.0/24 | US | arin | 1992-12-01",
+		"as15169.asn.cymru.com":        "15169 | US | arin | 2000-03-30 | GOOGLE, US",
+	})
+
+	d := NewDNSBackend(WithDNSServer(addr))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	results, _, err := d.Query(ctx, []string{"8.8.8.8", "203.0.113.1"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(results) != 1 {
+		t.Fatalf("expected 1 result, got %d", len(results))
+	}
+
+	if results[0].ASN != 15169 || results[0].ASName != "GOOGLE, US" {
+		t.Errorf("unexpected result: %+v", results[0])
+	}
+}
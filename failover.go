@@ -0,0 +1,198 @@
+package cymruasn
+
+import (
+	"context"
+	"errors"
+	"net"
+	"slices"
+	"sync"
+	"time"
+)
+
+// DefaultServerCooldown is the default time a whois server that failed is
+// avoided.
+const DefaultServerCooldown = 30 * time.Second
+
+// ServerStatus describes the health of a whois server.
+type ServerStatus struct {
+	Addr string
+	// Healthy is false while the server is in cooldown after a failure.
+	Healthy bool
+	// Failures counts consecutive failures.
+	Failures  int
+	DownUntil time.Time
+	LastError error
+}
+
+// WithServers sets an ordered list of whois servers, each given as
+// host:port or as a host using the configured port. Queries go to the
+// first healthy server and fail over to the next on connection or read
+// errors; a server that failed is avoided for the cooldown period. It
+// replaces WithServer.
+func WithServers(addrs ...string) Option {
+	return func(c *Client) {
+		c.serverAddrs = addrs
+	}
+}
+
+// WithServerCooldown sets how long a whois server that failed is avoided.
+func WithServerCooldown(cooldown time.Duration) Option {
+	return func(c *Client) {
+		c.cooldown = cooldown
+	}
+}
+
+// endpoint is a whois server and its health.
+type endpoint struct {
+	addr string
+
+	mu        sync.Mutex
+	failures  int
+	downUntil time.Time
+	lastErr   error
+}
+
+// newEndpoints returns the endpoints for the given addresses, adding port
+// to those without one.
+func newEndpoints(addrs []string, port string) []*endpoint {
+	endpoints := make([]*endpoint, len(addrs))
+	for i, addr := range addrs {
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			addr = net.JoinHostPort(addr, port)
+		}
+		endpoints[i] = &endpoint{addr: addr}
+	}
+	return endpoints
+}
+
+func (e *endpoint) markDown(until time.Time, err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.failures++
+	e.downUntil = until
+	e.lastErr = err
+}
+
+func (e *endpoint) markUp() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.failures = 0
+	e.downUntil = time.Time{}
+	e.lastErr = nil
+}
+
+func (e *endpoint) status(now time.Time) ServerStatus {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return ServerStatus{
+		Addr:      e.addr,
+		Healthy:   !now.Before(e.downUntil),
+		Failures:  e.failures,
+		DownUntil: e.downUntil,
+		LastError: e.lastErr,
+	}
+}
+
+// ServerStatus returns the health of each whois server, in the configured
+// order.
+func (c *Client) ServerStatus() []ServerStatus {
+	now := c.clock.Now()
+
+	statuses := make([]ServerStatus, len(c.endpoints))
+	for i, e := range c.endpoints {
+		statuses[i] = e.status(now)
+	}
+	return statuses
+}
+
+// orderedEndpoints returns the healthy endpoints in configured order,
+// followed by those in cooldown, soonest to recover first, so that a query
+// is still tried when every server is down.
+func (c *Client) orderedEndpoints() []*endpoint {
+	now := c.clock.Now()
+
+	var healthy []*endpoint
+	var down []*endpoint
+	until := make(map[*endpoint]time.Time)
+
+	for _, e := range c.endpoints {
+		s := e.status(now)
+		if s.Healthy {
+			healthy = append(healthy, e)
+			continue
+		}
+		down = append(down, e)
+		until[e] = s.DownUntil
+	}
+
+	slices.SortStableFunc(down, func(a, b *endpoint) int {
+		return until[a].Compare(until[b])
+	})
+
+	return append(healthy, down...)
+}
+
+// failed records a failure of e and reports whether the query should fail
+// over to the next server. Failures caused by the caller's context or by a
+// response too large for any server are not held against the server.
+func (c *Client) failed(ctx context.Context, e *endpoint, err error) bool {
+	if ctx.Err() != nil || errors.Is(err, ErrResponseTooLarge) || errors.Is(err, ErrLineTooLong) {
+		return false
+	}
+
+	e.markDown(c.clock.Now().Add(c.cooldown), err)
+	return true
+}
+
+// fetchWhois sends the request to the first available whois server,
+// failing over to the next on errors. It returns the complete response and
+// the address of the server that answered.
+func (c *Client) fetchWhois(ctx context.Context, request []byte) ([]byte, string, error) {
+	var err error
+
+	for _, e := range c.orderedEndpoints() {
+		var response []byte
+		response, err = c.fetch(ctx, e.addr, request)
+		if err == nil {
+			err = checkResponse(response)
+		}
+
+		if err == nil {
+			e.markUp()
+			return response, e.addr, nil
+		}
+
+		if !c.failed(ctx, e, err) {
+			return nil, "", err
+		}
+	}
+
+	return nil, "", err
+}
+
+// checkResponse rejects empty responses and responses that end part way
+// through a line.
+func checkResponse(response []byte) error {
+	if len(response) == 0 {
+		return ErrEmptyResponse
+	}
+	if response[len(response)-1] != '\n' {
+		return ErrTruncatedResponse
+	}
+	return nil
+}
+
+// serversOf returns the distinct servers that answered the results, in
+// order of first appearance.
+func serversOf(results []Result) []string {
+	var servers []string
+	for _, r := range results {
+		if r.Server != "" && !slices.Contains(servers, r.Server) {
+			servers = append(servers, r.Server)
+		}
+	}
+	return servers
+}
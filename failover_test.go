@@ -0,0 +1,239 @@
+package cymruasn
+
+import (
+	"context"
+	"net"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const failoverResponse = `Bulk mode; whois.cymru.com [2024-01-15 12:00:00 +0000]
+15169   | 8.8.8.8          | 8.8.8.0/24       | US | GOOGLE, US
+`
+
+// startCountingServer starts a whois server that answers every connection
+// with response and counts the connections it accepts.
+func startCountingServer(t *testing.T, response string) (string, *atomic.Int32) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to create listener: %v", err)
+	}
+	t.Cleanup(func() {
+		if closeErr := listener.Close(); closeErr != nil {
+			t.Logf("failed to close listener: %v", closeErr)
+		}
+	})
+
+	var conns atomic.Int32
+	go func() {
+		for {
+			conn, acceptErr := listener.Accept()
+			if acceptErr != nil {
+				return
+			}
+			conns.Add(1)
+
+			go func() {
+				defer conn.Close()
+
+				buf := make([]byte, 64*1024)
+				if _, readErr := conn.Read(buf); readErr != nil {
+					return
+				}
+				conn.Write([]byte(response))
+			}()
+		}
+	}()
+
+	return listener.Addr().String(), &conns
+}
+
+// closedAddr returns the address of a port nothing is listening on.
+func closedAddr(t *testing.T) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to create listener: %v", err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+	return addr
+}
+
+func TestWithServersAddsPort(t *testing.T) {
+	c := NewClient(WithServers("whois.example.net", "192.0.2.1:4343", "2001:db8::1"), WithPort("4343"))
+
+	var got []string
+	for _, s := range c.ServerStatus() {
+		got = append(got, s.Addr)
+	}
+
+	want := []string{"whois.example.net:4343", "192.0.2.1:4343", "[2001:db8::1]:4343"}
+	if !slices.Equal(got, want) {
+		t.Errorf("servers = %v, want %v", got, want)
+	}
+}
+
+func TestLookupFailover(t *testing.T) {
+	down := closedAddr(t)
+	up, conns := startCountingServer(t, failoverResponse)
+
+	c := NewClient(WithServers(down, up), WithTimeout(5*time.Second))
+	clock := newFakeClock()
+	c.clock = clock
+
+	resp, err := c.Lookup(context.Background(), []string{"8.8.8.8"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(resp.Results) != 1 || resp.Results[0].Server != up {
+		t.Fatalf("expected a result from %s, got %+v", up, resp.Results)
+	}
+	if !slices.Equal(resp.Servers, []string{up}) {
+		t.Errorf("Servers = %v, want [%s]", resp.Servers, up)
+	}
+
+	status := c.ServerStatus()
+	if status[0].Healthy || status[0].Failures != 1 || status[0].LastError == nil {
+		t.Errorf("expected %s in cooldown, got %+v", down, status[0])
+	}
+	if !status[1].Healthy {
+		t.Errorf("expected %s healthy, got %+v", up, status[1])
+	}
+	if want := clock.Now().Add(DefaultServerCooldown); !status[0].DownUntil.Equal(want) {
+		t.Errorf("DownUntil = %v, want %v", status[0].DownUntil, want)
+	}
+
+	if n := conns.Load(); n != 1 {
+		t.Errorf("expected 1 connection to %s, got %d", up, n)
+	}
+}
+
+func TestLookupFailoverOnTruncatedResponse(t *testing.T) {
+	truncated, _ := startCountingServer(t, "Bulk mode; whois.cymru.com [2024-01-15 12:00:00 +0000]\n15169   | 8.8.8.8")
+	up, _ := startCountingServer(t, failoverResponse)
+
+	c := NewClient(WithServers(truncated, up), WithTimeout(5*time.Second))
+
+	resp, err := c.Lookup(context.Background(), []string{"8.8.8.8"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(resp.Results) != 1 || resp.Results[0].Server != up {
+		t.Fatalf("expected a result from %s, got %+v", up, resp.Results)
+	}
+	if status := c.ServerStatus(); status[0].Healthy {
+		t.Errorf("expected %s in cooldown, got %+v", truncated, status[0])
+	}
+}
+
+func TestLookupServerCooldown(t *testing.T) {
+	first, firstConns := startCountingServer(t, "")
+	second, secondConns := startCountingServer(t, failoverResponse)
+
+	c := NewClient(WithServers(first, second), WithServerCooldown(time.Minute), WithTimeout(5*time.Second))
+	clock := newFakeClock()
+	c.clock = clock
+
+	lookup := func() *Response {
+		t.Helper()
+		resp, err := c.Lookup(context.Background(), []string{"8.8.8.8"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return resp
+	}
+
+	// The first server answers empty and is put in cooldown.
+	if resp := lookup(); !slices.Equal(resp.Servers, []string{second}) {
+		t.Fatalf("Servers = %v, want [%s]", resp.Servers, second)
+	}
+
+	// While in cooldown it is skipped.
+	clock.Sleep(context.Background(), 30*time.Second)
+	lookup()
+	if n := firstConns.Load(); n != 1 {
+		t.Errorf("expected 1 connection to %s during cooldown, got %d", first, n)
+	}
+
+	// After cooldown it is tried first again.
+	clock.Sleep(context.Background(), 31*time.Second)
+	lookup()
+	if n := firstConns.Load(); n != 2 {
+		t.Errorf("expected 2 connections to %s after cooldown, got %d", first, n)
+	}
+	if n := secondConns.Load(); n != 3 {
+		t.Errorf("expected 3 connections to %s, got %d", second, n)
+	}
+}
+
+func TestLookupAllServersDown(t *testing.T) {
+	first := closedAddr(t)
+	second := closedAddr(t)
+
+	c := NewClient(WithServers(first, second), WithTimeout(5*time.Second))
+	clock := newFakeClock()
+	c.clock = clock
+
+	if _, err := c.Lookup(context.Background(), []string{"8.8.8.8"}); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+
+	for _, s := range c.ServerStatus() {
+		if s.Healthy {
+			t.Errorf("expected %s in cooldown, got %+v", s.Addr, s)
+		}
+	}
+
+	// With every server in cooldown, they are still tried, soonest to
+	// recover first.
+	clock.Sleep(context.Background(), time.Second)
+	c.endpoints[1].markDown(clock.Now(), nil)
+	if got := c.orderedEndpoints(); got[0].addr != second || got[1].addr != first {
+		t.Errorf("order = %s, %s, want %s, %s", got[0].addr, got[1].addr, second, first)
+	}
+}
+
+func TestLookupStreamFailover(t *testing.T) {
+	down := closedAddr(t)
+	up, _ := startCountingServer(t, failoverResponse)
+
+	c := NewClient(WithServers(down, up), WithTimeout(5*time.Second))
+
+	var results []Result
+	for r, err := range c.LookupStream(context.Background(), []string{"8.8.8.8"}) {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		results = append(results, r)
+	}
+
+	if len(results) != 1 || results[0].Server != up {
+		t.Fatalf("expected a result from %s, got %+v", up, results)
+	}
+	if status := c.ServerStatus(); status[0].Healthy {
+		t.Errorf("expected %s in cooldown, got %+v", down, status[0])
+	}
+}
+
+func TestLookupASNsFailover(t *testing.T) {
+	down := closedAddr(t)
+	up, _ := startCountingServer(t, "Bulk mode; whois.cymru.com [2024-01-15 12:00:00 +0000]\n15169   | US | arin     | 2000-03-30 | GOOGLE, US\n")
+
+	c := NewClient(WithServers(down, up), WithTimeout(5*time.Second))
+
+	resp, err := c.LookupASNs(context.Background(), []uint32{15169})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(resp.Results) != 1 {
+		t.Errorf("expected 1 result, got %+v", resp)
+	}
+}
@@ -2,4 +2,4 @@ module github.com/superfrink/go-cymru-asn
 
 go 1.24.0
 
-require golang.org/x/sys v0.40.0 // indirect
+require golang.org/x/sys v0.40.0
@@ -0,0 +1,213 @@
+package cymruasn
+
+import (
+	"context"
+	"sync"
+)
+
+// inflightGroup tracks the IPs with a query in flight, so that concurrent
+// lookups of the same IP share one query instead of each sending their own.
+type inflightGroup struct {
+	mu      sync.Mutex
+	flights map[string]*flight
+}
+
+// flight is the pending answer for one IP.
+type flight struct {
+	key   string
+	query *flightQuery
+	done  chan struct{}
+
+	// result or err is set before done is closed.
+	result Result
+	found  bool
+	err    LookupError
+
+	// refs counts the callers waiting for the answer. It and finished are
+	// guarded by the group's mutex.
+	refs     int
+	finished bool
+}
+
+// flightQuery is one query answering a set of flights. Its context is
+// cancelled once no caller is waiting for any of its flights.
+type flightQuery struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	ips     []string
+	flights []*flight
+	waiting int
+
+	// batches and err are set before the flights are finished.
+	batches batchResult
+	err     error
+}
+
+// join registers the caller's interest in each IP. IPs already in flight
+// are joined; the others become flights of a new query, returned for the
+// caller to run, or nil if every IP was already in flight. The query's
+// context keeps ctx's values but not its cancellation.
+func (g *inflightGroup) join(ctx context.Context, ips []string) ([]*flight, *flightQuery) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.flights == nil {
+		g.flights = make(map[string]*flight)
+	}
+
+	var q *flightQuery
+	flights := make([]*flight, len(ips))
+
+	for i, ip := range ips {
+		key := canonicalIP(ip)
+
+		if f, ok := g.flights[key]; ok {
+			f.refs++
+			flights[i] = f
+			continue
+		}
+
+		if q == nil {
+			q = &flightQuery{}
+			q.ctx, q.cancel = context.WithCancel(context.WithoutCancel(ctx))
+		}
+
+		f := &flight{key: key, query: q, done: make(chan struct{}), refs: 1}
+		g.flights[key] = f
+		q.ips = append(q.ips, ip)
+		q.flights = append(q.flights, f)
+		q.waiting++
+		flights[i] = f
+	}
+
+	return flights, q
+}
+
+// leave withdraws the caller's interest in the flights. A flight nobody
+// waits for is forgotten, so later callers start a fresh query, and a
+// query with no flights left waiting is cancelled.
+func (g *inflightGroup) leave(flights []*flight) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for _, f := range flights {
+		if f.finished {
+			continue
+		}
+
+		f.refs--
+		if f.refs > 0 {
+			continue
+		}
+
+		if g.flights[f.key] == f {
+			delete(g.flights, f.key)
+		}
+
+		f.query.waiting--
+		if f.query.waiting == 0 {
+			f.query.cancel()
+		}
+	}
+}
+
+// finish delivers the outcome of query q to its flights.
+func (g *inflightGroup) finish(q *flightQuery) {
+	results := make(map[string]Result, len(q.batches.results))
+	for _, r := range q.batches.results {
+		results[canonicalIP(r.IP)] = r
+	}
+
+	errs := make(map[string]LookupError, len(q.batches.errs))
+	for _, e := range q.batches.errs {
+		errs[canonicalIP(e.IP)] = e
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for i, f := range q.flights {
+		switch r, ok := results[f.key]; {
+		case q.err != nil:
+			f.err = LookupError{IP: q.ips[i], Err: q.err}
+		case ok:
+			f.result, f.found = r, true
+		default:
+			f.err = errs[f.key]
+		}
+
+		f.finished = true
+		if g.flights[f.key] == f {
+			delete(g.flights, f.key)
+		}
+		close(f.done)
+	}
+}
+
+// queryShared looks up the IPs, sharing queries with concurrent lookups of
+// the same IPs. The caller's context only bounds its own wait: a query
+// another caller is waiting for is not cancelled with it. The returned
+// error is non-nil when ctx is done or when every query answering the
+// caller's IPs failed as a whole, whichever caller started them.
+func (c *Client) queryShared(ctx context.Context, ips []string) (batchResult, error) {
+	flights, q := c.inflight.join(ctx, ips)
+
+	if q != nil {
+		go func() {
+			defer q.cancel()
+
+			q.batches, q.err = c.queryBatches(q.ctx, q.ips)
+			if q.err == nil {
+				c.storeInCache(q.batches.results)
+			}
+			c.inflight.finish(q)
+		}()
+	}
+
+	for _, f := range flights {
+		select {
+		case <-f.done:
+		case <-ctx.Done():
+			c.inflight.leave(flights)
+			return batchResult{}, ctx.Err()
+		}
+	}
+
+	if err := queryFailed(flights); err != nil {
+		return batchResult{}, err
+	}
+
+	var out batchResult
+
+	if q != nil {
+		out.parseErrs = q.batches.parseErrs
+		out.attempts = q.batches.attempts
+	}
+
+	for i, f := range flights {
+		if f.found {
+			r := f.result
+			r.IP = ips[i]
+			out.results = append(out.results, r)
+			continue
+		}
+
+		e := f.err
+		e.IP = ips[i]
+		out.errs = append(out.errs, e)
+	}
+
+	return out, nil
+}
+
+// queryFailed returns the error of the first query answering the finished
+// flights if every one of those queries failed as a whole, and nil
+// otherwise.
+func queryFailed(flights []*flight) error {
+	for _, f := range flights {
+		if f.query.err == nil {
+			return nil
+		}
+	}
+	return flights[0].query.err
+}
@@ -0,0 +1,186 @@
+package cymruasn
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+// blockingBackend holds each query until released and reports queries as
+// they start. Released queries fail with err if it is set.
+type blockingBackend struct {
+	started chan []string
+	release chan struct{}
+	err     error
+}
+
+func newBlockingBackend() *blockingBackend {
+	return &blockingBackend{
+		started: make(chan []string, 10),
+		release: make(chan struct{}),
+	}
+}
+
+func (b *blockingBackend) Query(ctx context.Context, ips []string) ([]Result, []ParseError, error) {
+	b.started <- ips
+
+	select {
+	case <-b.release:
+	case <-ctx.Done():
+		return nil, nil, ctx.Err()
+	}
+
+	if b.err != nil {
+		return nil, nil, b.err
+	}
+
+	results := make([]Result, len(ips))
+	for i, ip := range ips {
+		results[i] = Result{IP: ip, ASN: 64500}
+	}
+	return results, nil, nil
+}
+
+// waitStarted returns the IPs of the next query to start.
+func (b *blockingBackend) waitStarted(t *testing.T) []string {
+	t.Helper()
+
+	select {
+	case ips := <-b.started:
+		return ips
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a query")
+		return nil
+	}
+}
+
+type lookupOutcome struct {
+	resp *Response
+	err  error
+}
+
+func lookupAsync(c *Client, ctx context.Context, ips []string) chan lookupOutcome {
+	ch := make(chan lookupOutcome, 1)
+	go func() {
+		resp, err := c.Lookup(ctx, ips)
+		ch <- lookupOutcome{resp, err}
+	}()
+	return ch
+}
+
+func TestLookupSharesInflight(t *testing.T) {
+	backend := newBlockingBackend()
+	c := NewClient(WithBackend(backend))
+
+	first := lookupAsync(c, context.Background(), []string{"11.0.0.1", "11.0.0.2"})
+	backend.waitStarted(t)
+
+	second := lookupAsync(c, context.Background(), []string{"11.0.0.2", "11.0.0.3"})
+	if got := backend.waitStarted(t); !slices.Equal(got, []string{"11.0.0.3"}) {
+		t.Errorf("expected only 11.0.0.3 to be queried again, got %v", got)
+	}
+
+	close(backend.release)
+
+	for _, ch := range []chan lookupOutcome{first, second} {
+		out := <-ch
+		if out.err != nil {
+			t.Fatalf("unexpected error: %v", out.err)
+		}
+		if len(out.resp.Results) != 2 || len(out.resp.Errors) != 0 {
+			t.Errorf("expected 2 results, got %+v", out.resp)
+		}
+	}
+}
+
+func TestLookupInflightCancelOne(t *testing.T) {
+	backend := newBlockingBackend()
+	c := NewClient(WithBackend(backend))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := lookupAsync(c, ctx, []string{"11.0.0.1"})
+	backend.waitStarted(t)
+
+	second := lookupAsync(c, context.Background(), []string{"11.0.0.1"})
+
+	// Give the second lookup time to join the flight before cancelling the
+	// first.
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+
+	if out := <-first; !errors.Is(out.err, context.Canceled) {
+		t.Errorf("expected the first lookup to be cancelled, got %v", out.err)
+	}
+
+	close(backend.release)
+
+	out := <-second
+	if out.err != nil {
+		t.Fatalf("expected the second lookup to succeed, got %v", out.err)
+	}
+	if len(out.resp.Results) != 1 || out.resp.Results[0].ASN != 64500 {
+		t.Errorf("unexpected response %+v", out.resp)
+	}
+}
+
+func TestLookupInflightCancelAll(t *testing.T) {
+	backend := newBlockingBackend()
+	c := NewClient(WithBackend(backend))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := lookupAsync(c, ctx, []string{"11.0.0.1"})
+	backend.waitStarted(t)
+
+	cancel()
+
+	if out := <-first; !errors.Is(out.err, context.Canceled) {
+		t.Errorf("expected the lookup to be cancelled, got %v", out.err)
+	}
+
+	// With nobody waiting, the query is cancelled and the IP is queried
+	// afresh by the next lookup.
+	next := lookupAsync(c, context.Background(), []string{"11.0.0.1"})
+	backend.waitStarted(t)
+	close(backend.release)
+
+	if out := <-next; out.err != nil || len(out.resp.Results) != 1 {
+		t.Errorf("expected a fresh query to succeed, got %+v, %v", out.resp, out.err)
+	}
+}
+
+func TestLookupSharedQueryFails(t *testing.T) {
+	backend := newBlockingBackend()
+	backend.err = errBatchFailed
+	c := NewClient(WithBackend(backend))
+
+	first := lookupAsync(c, context.Background(), []string{"8.8.8.8"})
+	backend.waitStarted(t)
+
+	second := lookupAsync(c, context.Background(), []string{"8.8.8.8"})
+
+	// Wait for the second lookup to join the first one's flight.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		c.inflight.mu.Lock()
+		refs := c.inflight.flights["8.8.8.8"].refs
+		c.inflight.mu.Unlock()
+		if refs == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the second lookup to join")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	close(backend.release)
+
+	for _, ch := range []chan lookupOutcome{first, second} {
+		out := <-ch
+		if !errors.Is(out.err, errBatchFailed) {
+			t.Errorf("expected %v from every caller, got %+v, %v", errBatchFailed, out.resp, out.err)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package cymruasn
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// iptoasnEntry holds the AS details of one iptoasn range.
+type iptoasnEntry struct {
+	asn         int
+	countryCode string
+	asName      string
+}
+
+// IPtoASNBackend answers lookups offline from an iptoasn.com range dump such
+// as ip2asn-combined.tsv. Ranges are not necessarily CIDR aligned, so
+// BGPPrefix is the smallest prefix covering the whole range.
+type IPtoASNBackend struct {
+	index rangeIndex[iptoasnEntry]
+}
+
+// LoadIPtoASN loads an iptoasn.com TSV file, plain or gzip-compressed.
+func LoadIPtoASN(path string) (*IPtoASNBackend, error) {
+	f, err := openDataFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ReadIPtoASN(f)
+}
+
+// ReadIPtoASN reads an iptoasn.com dataset from r. Each line holds the
+// range start, range end, AS number, country code and AS description,
+// separated by tabs:
+//
+//	1.0.0.0	1.0.0.255	13335	US	CLOUDFLARENET
+//
+// Ranges with AS number 0 are not routed and are left out of the index.
+func ReadIPtoASN(r io.Reader) (*IPtoASNBackend, error) {
+	r, err := maybeGunzip(r)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &IPtoASNBackend{}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+
+		line := strings.TrimRight(scanner.Text(), "\r\n")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		start, end, entry, err := parseIPtoASNLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("iptoasn line %d: %w", lineNum, err)
+		}
+
+		if entry.asn == 0 {
+			continue
+		}
+
+		b.index.add(start, end, entry)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	b.index.sort()
+
+	return b, nil
+}
+
+// parseIPtoASNLine parses a single iptoasn line.
+func parseIPtoASNLine(line string) (netip.Addr, netip.Addr, iptoasnEntry, error) {
+	fields := strings.Split(line, "\t")
+	if len(fields) < 5 {
+		return netip.Addr{}, netip.Addr{}, iptoasnEntry{}, ErrInvalidFormat
+	}
+
+	start, err := netip.ParseAddr(fields[0])
+	if err != nil {
+		return netip.Addr{}, netip.Addr{}, iptoasnEntry{}, err
+	}
+
+	end, err := netip.ParseAddr(fields[1])
+	if err != nil {
+		return netip.Addr{}, netip.Addr{}, iptoasnEntry{}, err
+	}
+
+	if start.Unmap().Is4() != end.Unmap().Is4() || end.Less(start) {
+		return netip.Addr{}, netip.Addr{}, iptoasnEntry{}, fmt.Errorf("invalid range %s-%s", start, end)
+	}
+
+	asn, err := strconv.ParseUint(fields[2], 10, 32)
+	if err != nil {
+		return netip.Addr{}, netip.Addr{}, iptoasnEntry{}, err
+	}
+
+	return start, end, iptoasnEntry{
+		asn:         int(asn),
+		countryCode: fields[3],
+		asName:      strings.Join(fields[4:], "\t"),
+	}, nil
+}
+
+// Len returns the number of routed ranges loaded.
+func (b *IPtoASNBackend) Len() int {
+	return b.index.len()
+}
+
+// Query looks up each IP by binary search over the ranges. IPs not covered
+// by a routed range are omitted from the results.
+func (b *IPtoASNBackend) Query(_ context.Context, ips []string) ([]Result, []ParseError, error) {
+	var results []Result
+
+	for _, ip := range ips {
+		addr, err := netip.ParseAddr(ip)
+		if err != nil {
+			continue
+		}
+
+		r, entry, ok := b.index.lookup(addr)
+		if !ok {
+			continue
+		}
+
+		covering := r.coveringPrefix()
+
+		results = append(results, Result{
+			IP:              ip,
+			ASN:             entry.asn,
+			ASNs:            []uint32{uint32(entry.asn)},
+			BGPPrefix:       covering.String(),
+			SyntheticPrefix: prefixRange(covering) != r,
+			CountryCode:     entry.countryCode,
+			ASName:          entry.asName,
+		})
+	}
+
+	return results, nil, nil
+}
@@ -0,0 +1,68 @@
+package cymruasn
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+const testIPtoASN = "1.0.0.0\t1.0.0.255\t13335\tUS\tCLOUDFLARENET\n" +
+	"1.0.1.0\t1.0.3.255\t0\tNone\tNot routed\n" +
+	"8.8.8.0\t8.8.8.255\t15169\tUS\tGOOGLE\n" +
+	"100.0.0.0\t100.0.2.255\t64500\tZZ\tEXAMPLE, WITH COMMA\n" +
+	"2001:4860::\t2001:4860:ffff:ffff:ffff:ffff:ffff:ffff\t15169\tUS\tGOOGLE\n"
+
+func TestReadIPtoASN(t *testing.T) {
+	b, err := ReadIPtoASN(strings.NewReader(testIPtoASN))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if b.Len() != 4 {
+		t.Errorf("expected 4 routed ranges, got %d", b.Len())
+	}
+
+	ips := []string{"1.0.0.1", "1.0.2.1", "8.8.8.8", "100.0.1.1", "2001:4860:4860::8888"}
+	results, _, err := b.Query(context.Background(), ips)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []Result{
+		{IP: "1.0.0.1", ASN: 13335, BGPPrefix: "1.0.0.0/24", CountryCode: "US", ASName: "CLOUDFLARENET"},
+		{IP: "8.8.8.8", ASN: 15169, BGPPrefix: "8.8.8.0/24", CountryCode: "US", ASName: "GOOGLE"},
+		{IP: "100.0.1.1", ASN: 64500, BGPPrefix: "100.0.0.0/22", CountryCode: "ZZ", ASName: "EXAMPLE, WITH COMMA"},
+		{IP: "2001:4860:4860::8888", ASN: 15169, BGPPrefix: "2001:4860::/32", CountryCode: "US", ASName: "GOOGLE"},
+	}
+
+	if len(results) != len(want) {
+		t.Fatalf("expected %d results, got %d", len(want), len(results))
+	}
+
+	for i, w := range want {
+		got := results[i]
+		if got.IP != w.IP || got.ASN != w.ASN || got.BGPPrefix != w.BGPPrefix || got.CountryCode != w.CountryCode || got.ASName != w.ASName {
+			t.Errorf("result %d = %+v, want %+v", i, got, w)
+		}
+	}
+}
+
+func TestReadIPtoASNInvalid(t *testing.T) {
+	tests := []string{
+		"1.0.0.0\t1.0.0.255\t13335\tUS",
+		"1.0.0.255\t1.0.0.0\t13335\tUS\tREVERSED",
+		"1.0.0.0\t2001:db8::\t13335\tUS\tMIXED",
+		"1.0.0.0\t1.0.0.255\tAS13335\tUS\tCLOUDFLARENET",
+	}
+
+	for _, input := range tests {
+		if _, err := ReadIPtoASN(strings.NewReader(input)); err == nil {
+			t.Errorf("expected error for %q, got nil", input)
+		}
+	}
+
+	if _, err := ReadIPtoASN(strings.NewReader("1.0.0.0\t1.0.0.255\t13335\tUS")); !errors.Is(err, ErrInvalidFormat) {
+		t.Errorf("expected ErrInvalidFormat for a short line, got %v", err)
+	}
+}
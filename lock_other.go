@@ -0,0 +1,11 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package cymruasn
+
+// lockFile does nothing on platforms without flock(2). Saves are then not
+// serialized: concurrent saves share the temporary file and may lose each
+// other's entries or leave a corrupt file, so only one process should save
+// a cache at a time.
+func lockFile(path string, exclusive bool) (func(), error) {
+	return func() {}, nil
+}
@@ -0,0 +1,113 @@
+package cymruasn
+
+import (
+	"net/netip"
+	"slices"
+)
+
+// prefixTable maps prefixes to values and answers longest-prefix-match
+// queries by probing each prefix length present, longest first. It keeps
+// one map entry per prefix, which is far smaller than a bit-per-node trie
+// for full routing tables.
+type prefixTable[T any] struct {
+	entries map[netip.Prefix]T
+	counts  [2][129]int
+	lengths [2][]int
+}
+
+// newPrefixTable returns an empty prefix table.
+func newPrefixTable[T any]() *prefixTable[T] {
+	return &prefixTable[T]{entries: make(map[netip.Prefix]T)}
+}
+
+// family returns the index of the address family of addr.
+func family(addr netip.Addr) int {
+	if addr.Is4() {
+		return 0
+	}
+	return 1
+}
+
+// normalizePrefix unmaps IPv4-mapped prefixes and clears host bits.
+func normalizePrefix(p netip.Prefix) netip.Prefix {
+	addr := p.Addr()
+	bits := p.Bits()
+	if addr.Is4In6() && bits >= 96 {
+		addr = addr.Unmap()
+		bits -= 96
+	}
+	return netip.PrefixFrom(addr.WithZone(""), bits).Masked()
+}
+
+// insert stores v for prefix p, replacing any existing value.
+func (t *prefixTable[T]) insert(p netip.Prefix, v T) {
+	p = normalizePrefix(p)
+
+	if _, ok := t.entries[p]; !ok {
+		f := family(p.Addr())
+		t.counts[f][p.Bits()]++
+		if t.counts[f][p.Bits()] == 1 {
+			t.updateLengths(f)
+		}
+	}
+
+	t.entries[p] = v
+}
+
+// remove deletes prefix p from the table.
+func (t *prefixTable[T]) remove(p netip.Prefix) {
+	p = normalizePrefix(p)
+
+	if _, ok := t.entries[p]; !ok {
+		return
+	}
+
+	delete(t.entries, p)
+
+	f := family(p.Addr())
+	t.counts[f][p.Bits()]--
+	if t.counts[f][p.Bits()] == 0 {
+		t.updateLengths(f)
+	}
+}
+
+// get returns the value stored for exactly prefix p.
+func (t *prefixTable[T]) get(p netip.Prefix) (T, bool) {
+	v, ok := t.entries[normalizePrefix(p)]
+	return v, ok
+}
+
+// lookup returns the most specific prefix containing addr and its value.
+func (t *prefixTable[T]) lookup(addr netip.Addr) (netip.Prefix, T, bool) {
+	addr = addr.Unmap().WithZone("")
+
+	for _, bits := range t.lengths[family(addr)] {
+		p, err := addr.Prefix(bits)
+		if err != nil {
+			continue
+		}
+		if v, ok := t.entries[p]; ok {
+			return p, v, true
+		}
+	}
+
+	var zero T
+	return netip.Prefix{}, zero, false
+}
+
+// len returns the number of prefixes in the table.
+func (t *prefixTable[T]) len() int {
+	return len(t.entries)
+}
+
+// updateLengths rebuilds the descending list of prefix lengths in use for
+// address family f.
+func (t *prefixTable[T]) updateLengths(f int) {
+	t.lengths[f] = t.lengths[f][:0]
+	for bits := len(t.counts[f]) - 1; bits >= 0; bits-- {
+		if t.counts[f][bits] > 0 {
+			t.lengths[f] = append(t.lengths[f], bits)
+		}
+	}
+	t.lengths[f] = slices.Clip(t.lengths[f])
+}
@@ -0,0 +1,61 @@
+package cymruasn
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestPrefixTable(t *testing.T) {
+	table := newPrefixTable[string]()
+	table.insert(netip.MustParsePrefix("10.0.0.0/8"), "a")
+	table.insert(netip.MustParsePrefix("10.1.0.0/16"), "b")
+	table.insert(netip.MustParsePrefix("10.1.2.99/24"), "c")
+	table.insert(netip.MustParsePrefix("2001:db8::/32"), "d")
+	table.insert(netip.MustParsePrefix("::/0"), "default6")
+
+	tests := []struct {
+		addr       string
+		wantPrefix string
+		wantValue  string
+		wantOK     bool
+	}{
+		{"10.1.2.3", "10.1.2.0/24", "c", true},
+		{"10.1.3.3", "10.1.0.0/16", "b", true},
+		{"10.200.0.1", "10.0.0.0/8", "a", true},
+		{"::ffff:10.1.3.3", "10.1.0.0/16", "b", true},
+		{"11.0.0.1", "", "", false},
+		{"2001:db8::1", "2001:db8::/32", "d", true},
+		{"2001:db9::1", "::/0", "default6", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			prefix, value, ok := table.lookup(netip.MustParseAddr(tt.addr))
+			if ok != tt.wantOK {
+				t.Fatalf("lookup(%s) ok = %v, want %v", tt.addr, ok, tt.wantOK)
+			}
+			if !ok {
+				return
+			}
+			if prefix.String() != tt.wantPrefix {
+				t.Errorf("lookup(%s) prefix = %s, want %s", tt.addr, prefix, tt.wantPrefix)
+			}
+			if value != tt.wantValue {
+				t.Errorf("lookup(%s) value = %s, want %s", tt.addr, value, tt.wantValue)
+			}
+		})
+	}
+
+	if table.len() != 5 {
+		t.Errorf("expected 5 entries, got %d", table.len())
+	}
+
+	table.remove(netip.MustParsePrefix("10.1.2.0/24"))
+	if prefix, _, _ := table.lookup(netip.MustParseAddr("10.1.2.3")); prefix.String() != "10.1.0.0/16" {
+		t.Errorf("expected 10.1.0.0/16 after removal, got %s", prefix)
+	}
+
+	if _, ok := table.get(netip.MustParsePrefix("10.1.0.0/16")); !ok {
+		t.Error("expected exact match for 10.1.0.0/16")
+	}
+}
@@ -0,0 +1,273 @@
+package cymruasn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"slices"
+)
+
+// DefaultProbeBudget is the default maximum number of addresses MapPrefix
+// queries.
+const DefaultProbeBudget = 1024
+
+// Default prefix lengths beyond which MapPrefix stops subdividing unrouted
+// space.
+const (
+	DefaultProbeGranularityV4 = 24
+	DefaultProbeGranularityV6 = 48
+)
+
+// PrefixMapping is one non-overlapping block of a mapped prefix.
+type PrefixMapping struct {
+	// Prefix is the block within the mapped prefix.
+	Prefix string
+	// Announced is the announced BGP prefix covering Prefix, which may be
+	// less specific than Prefix. It is empty for unrouted blocks.
+	Announced string
+	ASN       int
+	ASNs      []uint32
+	ASName    string
+	// SpecialPurpose is set when the block lies in special-purpose space.
+	SpecialPurpose *SpecialPurpose
+}
+
+// PrefixMap is the routing coverage of a prefix discovered by MapPrefix.
+type PrefixMap struct {
+	// Prefix is the mapped prefix.
+	Prefix string
+	// Entries cover the mapped prefix without overlapping, ordered by
+	// address.
+	Entries []PrefixMapping
+	// Unresolved lists blocks left unexplored when the probe budget ran out
+	// or their probe failed.
+	Unresolved []string
+	// Queries is the number of addresses probed.
+	Queries int
+}
+
+// MapOption configures MapPrefix.
+type MapOption func(*mapConfig)
+
+type mapConfig struct {
+	budget        int
+	granularityV4 int
+	granularityV6 int
+}
+
+// WithProbeBudget sets the maximum number of addresses MapPrefix queries.
+func WithProbeBudget(n int) MapOption {
+	return func(m *mapConfig) {
+		m.budget = n
+	}
+}
+
+// WithProbeGranularity sets the prefix lengths, for IPv4 and IPv6, beyond
+// which MapPrefix stops subdividing unrouted space.
+func WithProbeGranularity(v4Bits, v6Bits int) MapOption {
+	return func(m *mapConfig) {
+		m.granularityV4 = v4Bits
+		m.granularityV6 = v6Bits
+	}
+}
+
+// probeAnswer is what a probe learnt about one address.
+type probeAnswer struct {
+	result Result
+	// covering is the announced prefix, or the special-purpose block,
+	// containing the probed address. It is invalid for unrouted addresses.
+	covering netip.Prefix
+	routed   bool
+	// failed is set when the probe's query failed, so nothing was learnt.
+	failed bool
+}
+
+// MapPrefix discovers every announced prefix and origin inside cidr. It
+// probes the first address of each unexplored block, uses the returned
+// BGPPrefix to skip space that is already covered, and subdivides the
+// gaps, sending each round of probes as one bulk lookup. Unrouted space is
+// not subdivided beyond the probe granularity. Blocks whose probe failed,
+// and the remaining blocks when the probe budget runs out, are reported as
+// Unresolved.
+// The function returns a non-nil error only for an invalid prefix or
+// connection-level failures.
+func (c *Client) MapPrefix(ctx context.Context, cidr string, opts ...MapOption) (*PrefixMap, error) {
+	cfg := mapConfig{
+		budget:        DefaultProbeBudget,
+		granularityV4: DefaultProbeGranularityV4,
+		granularityV6: DefaultProbeGranularityV6,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	root, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid prefix: %s", cidr)
+	}
+	root = normalizePrefix(root)
+
+	granularity := cfg.granularityV6
+	if root.Addr().Is4() {
+		granularity = cfg.granularityV4
+	}
+
+	m := &PrefixMap{Prefix: root.String()}
+	answers := make(map[netip.Addr]probeAnswer)
+	pending := []netip.Prefix{root}
+
+	for len(pending) > 0 {
+		var probes []string
+		seen := make(map[netip.Addr]bool)
+		for _, p := range pending {
+			if _, ok := answers[p.Addr()]; !ok && !seen[p.Addr()] {
+				seen[p.Addr()] = true
+				probes = append(probes, p.Addr().String())
+			}
+		}
+
+		if remaining := cfg.budget - m.Queries; len(probes) > remaining {
+			probes = probes[:max(remaining, 0)]
+		}
+
+		if len(probes) > 0 {
+			if err := c.probe(ctx, probes, answers); err != nil {
+				return nil, err
+			}
+			m.Queries += len(probes)
+		}
+
+		var next []netip.Prefix
+		progressed := false
+
+		for _, p := range pending {
+			a, ok := answers[p.Addr()]
+			if !ok {
+				next = append(next, p)
+				continue
+			}
+			progressed = true
+
+			switch {
+			case a.failed:
+				m.Unresolved = append(m.Unresolved, p.String())
+			case a.covering.IsValid() && a.covering.Bits() <= p.Bits():
+				m.Entries = append(m.Entries, newPrefixMapping(p, a))
+			case a.covering.IsValid():
+				m.Entries = append(m.Entries, newPrefixMapping(a.covering, a))
+				next = append(next, prefixComplement(p, a.covering)...)
+			case p.Bits() >= granularity:
+				m.Entries = append(m.Entries, newPrefixMapping(p, a))
+			default:
+				lower, upper := splitPrefix(p)
+				next = append(next, lower, upper)
+			}
+		}
+
+		pending = next
+
+		if !progressed {
+			break
+		}
+	}
+
+	for _, p := range pending {
+		m.Unresolved = append(m.Unresolved, p.String())
+	}
+
+	slices.SortFunc(m.Entries, func(a, b PrefixMapping) int {
+		pa := netip.MustParsePrefix(a.Prefix)
+		pb := netip.MustParsePrefix(b.Prefix)
+		return pa.Addr().Compare(pb.Addr())
+	})
+
+	return m, nil
+}
+
+// probe looks up the given addresses and records what was learnt.
+// Addresses the server had no result for are recorded as unrouted, and
+// those whose query failed as failed.
+func (c *Client) probe(ctx context.Context, probes []string, answers map[netip.Addr]probeAnswer) error {
+	resp, err := c.Lookup(ctx, probes)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range resp.Results {
+		addr, err := netip.ParseAddr(r.IP)
+		if err != nil {
+			continue
+		}
+		addr = addr.Unmap().WithZone("")
+
+		a := probeAnswer{result: r}
+
+		covering := r.BGPPrefix
+		if r.SpecialPurpose != nil {
+			covering = r.SpecialPurpose.Prefix
+		}
+
+		if p, err := netip.ParsePrefix(covering); err == nil {
+			p = normalizePrefix(p)
+			if p.Contains(addr) {
+				a.covering = p
+				a.routed = r.SpecialPurpose == nil
+			}
+		}
+
+		answers[addr] = a
+	}
+
+	for _, e := range resp.Errors {
+		addr := netip.MustParseAddr(e.IP)
+		answers[addr] = probeAnswer{failed: !errors.Is(e.Err, ErrNoResult)}
+	}
+
+	return nil
+}
+
+// newPrefixMapping describes block p using what a probe learnt.
+func newPrefixMapping(p netip.Prefix, a probeAnswer) PrefixMapping {
+	m := PrefixMapping{
+		Prefix:         p.String(),
+		SpecialPurpose: a.result.SpecialPurpose,
+	}
+
+	if a.routed {
+		m.Announced = a.covering.String()
+		m.ASN = a.result.ASN
+		m.ASNs = a.result.ASNs
+		m.ASName = a.result.ASName
+	}
+
+	return m
+}
+
+// splitPrefix returns the two halves of p.
+func splitPrefix(p netip.Prefix) (netip.Prefix, netip.Prefix) {
+	bits := p.Bits() + 1
+	lower := netip.PrefixFrom(p.Addr(), bits)
+	upper := netip.PrefixFrom(flipBit(p.Addr(), p.Bits()), bits)
+	return lower, upper
+}
+
+// prefixComplement returns the prefixes that cover outer minus inner,
+// where inner lies within outer.
+func prefixComplement(outer, inner netip.Prefix) []netip.Prefix {
+	var rest []netip.Prefix
+	for bits := inner.Bits(); bits > outer.Bits(); bits-- {
+		ancestor, _ := inner.Addr().Prefix(bits)
+		rest = append(rest, netip.PrefixFrom(flipBit(ancestor.Addr(), bits-1), bits))
+	}
+	return rest
+}
+
+// flipBit returns addr with bit i, counted from the most significant bit,
+// inverted.
+func flipBit(addr netip.Addr, i int) netip.Addr {
+	b := addr.AsSlice()
+	b[i/8] ^= 0x80 >> uint(i%8)
+	flipped, _ := netip.AddrFromSlice(b)
+	return flipped
+}
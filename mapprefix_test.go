@@ -0,0 +1,238 @@
+package cymruasn
+
+import (
+	"context"
+	"net/netip"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const testMapPfx2as = `23.0.0.0	17	64500
+23.0.128.0	24	64501
+23.0.200.0	21	64502
+8.0.0.0	9	3356
+`
+
+// countingBackend records the IPs queried through it.
+type countingBackend struct {
+	Backend
+	queries [][]string
+}
+
+func (b *countingBackend) Query(ctx context.Context, ips []string) ([]Result, []ParseError, error) {
+	b.queries = append(b.queries, ips)
+	return b.Backend.Query(ctx, ips)
+}
+
+func newMapTestClient(t *testing.T) (*Client, *countingBackend) {
+	t.Helper()
+
+	pfx2as, err := ReadPfx2as(strings.NewReader(testMapPfx2as))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	backend := &countingBackend{Backend: pfx2as}
+	return NewClient(WithBackend(backend)), backend
+}
+
+func TestMapPrefix(t *testing.T) {
+	c, backend := newMapTestClient(t)
+
+	m, err := c.MapPrefix(context.Background(), "23.0.0.0/16")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(m.Unresolved) != 0 {
+		t.Errorf("expected no unresolved prefixes, got %v", m.Unresolved)
+	}
+
+	var size uint64
+	var last netip.Addr
+
+	for _, e := range m.Entries {
+		p := netip.MustParsePrefix(e.Prefix)
+		if last.IsValid() && p.Addr().Compare(last) <= 0 {
+			t.Errorf("entry %s overlaps or is out of order", e.Prefix)
+		}
+		r := prefixRange(p)
+		last = r.end
+		size += 1 << (32 - p.Bits())
+
+		if e.Announced != "" && e.Prefix != e.Announced {
+			t.Errorf("entry %s announced as %s", e.Prefix, e.Announced)
+		}
+	}
+
+	if size != 1<<16 {
+		t.Errorf("entries cover %d addresses, want %d", size, 1<<16)
+	}
+
+	var asns []int
+	var prefixes []string
+	for _, e := range m.Entries {
+		if e.Announced != "" {
+			prefixes = append(prefixes, e.Prefix)
+			asns = append(asns, e.ASN)
+		}
+	}
+
+	wantPrefixes := []string{"23.0.0.0/17", "23.0.128.0/24", "23.0.200.0/21"}
+	wantASNs := []int{64500, 64501, 64502}
+	if !slices.Equal(prefixes, wantPrefixes) || !slices.Equal(asns, wantASNs) {
+		t.Errorf("routed entries = %v %v, want %v %v", prefixes, asns, wantPrefixes, wantASNs)
+	}
+
+	total := 0
+	for _, q := range backend.queries {
+		total += len(q)
+	}
+	if m.Queries != total {
+		t.Errorf("Queries = %d, backend saw %d", m.Queries, total)
+	}
+	if len(backend.queries) >= m.Queries {
+		t.Errorf("expected probes to be batched, got %d queries for %d probes", len(backend.queries), m.Queries)
+	}
+}
+
+func TestMapPrefixCovered(t *testing.T) {
+	c, backend := newMapTestClient(t)
+
+	m, err := c.MapPrefix(context.Background(), "8.8.8.0/24")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if m.Queries != 1 || len(backend.queries) != 1 {
+		t.Errorf("expected a single probe, got %d", m.Queries)
+	}
+
+	if len(m.Entries) != 1 {
+		t.Fatalf("expected 1 entry, got %+v", m.Entries)
+	}
+
+	e := m.Entries[0]
+	if e.Prefix != "8.8.8.0/24" || e.Announced != "8.0.0.0/9" || e.ASN != 3356 {
+		t.Errorf("unexpected entry %+v", e)
+	}
+}
+
+func TestMapPrefixBudget(t *testing.T) {
+	c, _ := newMapTestClient(t)
+
+	m, err := c.MapPrefix(context.Background(), "23.0.0.0/16", WithProbeBudget(2))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if m.Queries != 2 {
+		t.Errorf("Queries = %d, want 2", m.Queries)
+	}
+
+	if len(m.Unresolved) == 0 {
+		t.Error("expected unresolved prefixes when the budget runs out")
+	}
+}
+
+func TestMapPrefixGranularity(t *testing.T) {
+	c, _ := newMapTestClient(t)
+
+	m, err := c.MapPrefix(context.Background(), "23.0.0.0/16", WithProbeGranularity(18, 48))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The /21 inside 23.0.192.0/18 is missed because its first address is
+	// unrouted and the /18 is not subdivided further.
+	found := false
+	for _, e := range m.Entries {
+		if e.Prefix == "23.0.200.0/21" {
+			t.Errorf("unexpected entry %+v", e)
+		}
+		if e.Prefix == "23.0.192.0/18" {
+			found = e.Announced == ""
+		}
+	}
+
+	if !found {
+		t.Error("expected 23.0.192.0/18 to be mapped as unrouted")
+	}
+}
+
+func TestMapPrefixInvalid(t *testing.T) {
+	c, _ := newMapTestClient(t)
+
+	if _, err := c.MapPrefix(context.Background(), "23.0.0.0"); err == nil {
+		t.Error("expected error, got nil")
+	}
+}
+
+func TestPrefixComplement(t *testing.T) {
+	outer := netip.MustParsePrefix("10.0.0.0/22")
+	inner := netip.MustParsePrefix("10.0.1.0/24")
+
+	var got []string
+	for _, p := range prefixComplement(outer, inner) {
+		got = append(got, p.String())
+	}
+
+	want := []string{"10.0.0.0/24", "10.0.2.0/23"}
+	if !slices.Equal(got, want) {
+		t.Errorf("prefixComplement = %v, want %v", got, want)
+	}
+
+	lower, upper := splitPrefix(netip.MustParsePrefix("2001:db8::/32"))
+	if lower.String() != "2001:db8::/33" || upper.String() != "2001:db8:8000::/33" {
+		t.Errorf("splitPrefix = %s %s", lower, upper)
+	}
+}
+
+// failingBackend fails every query that includes the IP fail.
+type failingBackend struct {
+	Backend
+	fail string
+}
+
+func (b *failingBackend) Query(ctx context.Context, ips []string) ([]Result, []ParseError, error) {
+	if slices.Contains(ips, b.fail) {
+		return nil, nil, errBatchFailed
+	}
+	return b.Backend.Query(ctx, ips)
+}
+
+func TestMapPrefixProbeFailed(t *testing.T) {
+	pfx2as, err := ReadPfx2as(strings.NewReader(testMapPfx2as))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// One probe per batch, so a failed probe does not fail the others.
+	backend := &countingBackend{Backend: &failingBackend{Backend: pfx2as, fail: "23.0.128.0"}}
+	c := NewClient(WithBackend(backend), WithBatchSize(1))
+
+	// The second round probes 23.0.128.0, which fails, and 23.1.0.0, which
+	// is unrouted.
+	m, err := c.MapPrefix(context.Background(), "23.0.0.0/15", WithProbeGranularity(16, 48))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !slices.Equal(m.Unresolved, []string{"23.0.128.0/17"}) {
+		t.Errorf("expected the failed block to be unresolved, got %v", m.Unresolved)
+	}
+
+	var got []string
+	for _, e := range m.Entries {
+		got = append(got, e.Prefix+" "+e.Announced)
+	}
+	want := []string{"23.0.0.0/17 23.0.0.0/17", "23.1.0.0/16 "}
+	if !slices.Equal(got, want) {
+		t.Errorf("entries = %q, want %q", got, want)
+	}
+
+	if m.Queries != 3 {
+		t.Errorf("expected the failed probe not to be repeated, got %d queries %v", m.Queries, backend.queries)
+	}
+}
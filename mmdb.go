@@ -0,0 +1,586 @@
+package cymruasn
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"net/netip"
+	"os"
+)
+
+// ErrInvalidMMDB is returned when a MaxMind DB file cannot be decoded.
+var ErrInvalidMMDB = errors.New("invalid MaxMind DB file")
+
+// mmdbMetadataMarker precedes the metadata section at the end of the file.
+var mmdbMetadataMarker = []byte("\xab\xcd\xefMaxMind.com")
+
+// mmdbMetadataMaxSize bounds the search for the metadata marker.
+const mmdbMetadataMaxSize = 128 * 1024
+
+// mmdbDataSeparatorSize is the number of zero bytes between the search tree
+// and the data section.
+const mmdbDataSeparatorSize = 16
+
+// mmdbMaxDepth bounds nesting while decoding data section values.
+const mmdbMaxDepth = 32
+
+// Data section field types.
+const (
+	mmdbExtended  = 0
+	mmdbPointer   = 1
+	mmdbString    = 2
+	mmdbDouble    = 3
+	mmdbBytes     = 4
+	mmdbUint16    = 5
+	mmdbUint32    = 6
+	mmdbMap       = 7
+	mmdbInt32     = 8
+	mmdbUint64    = 9
+	mmdbUint128   = 10
+	mmdbArray     = 11
+	mmdbContainer = 12
+	mmdbEndMarker = 13
+	mmdbBool      = 14
+	mmdbFloat     = 15
+)
+
+// MMDBMetadata describes a MaxMind DB file.
+type MMDBMetadata struct {
+	NodeCount                uint32
+	RecordSize               uint16
+	IPVersion                uint16
+	DatabaseType             string
+	Languages                []string
+	Description              map[string]string
+	BinaryFormatMajorVersion uint16
+	BinaryFormatMinorVersion uint16
+	BuildEpoch               uint64
+}
+
+// mmdbReader reads the binary search tree and data section of a MaxMind DB
+// file held in memory.
+type mmdbReader struct {
+	buf       []byte
+	metadata  MMDBMetadata
+	nodeSize  int
+	treeSize  int
+	data      []byte
+	ipv4Start uint32
+	ipv4Depth int
+}
+
+// newMMDBReader parses the metadata of the MaxMind DB file in buf and
+// prepares it for lookups.
+func newMMDBReader(buf []byte) (*mmdbReader, error) {
+	searchStart := max(len(buf)-mmdbMetadataMaxSize, 0)
+	markerIdx := bytes.LastIndex(buf[searchStart:], mmdbMetadataMarker)
+	if markerIdx < 0 {
+		return nil, fmt.Errorf("%w: metadata marker not found", ErrInvalidMMDB)
+	}
+	metaStart := searchStart + markerIdx + len(mmdbMetadataMarker)
+
+	metaDecoder := mmdbDecoder{buf: buf[metaStart:]}
+	raw, _, err := metaDecoder.decode(0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("%w: metadata: %v", ErrInvalidMMDB, err)
+	}
+
+	metadata, err := parseMMDBMetadata(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &mmdbReader{buf: buf, metadata: metadata}
+
+	switch metadata.RecordSize {
+	case 24, 28, 32:
+		r.nodeSize = int(metadata.RecordSize) * 2 / 8
+	default:
+		return nil, fmt.Errorf("%w: unsupported record size %d", ErrInvalidMMDB, metadata.RecordSize)
+	}
+
+	r.treeSize = int(metadata.NodeCount) * r.nodeSize
+	dataStart := r.treeSize + mmdbDataSeparatorSize
+	dataEnd := searchStart + markerIdx
+	if dataStart > dataEnd {
+		return nil, fmt.Errorf("%w: search tree exceeds file size", ErrInvalidMMDB)
+	}
+	r.data = buf[dataStart:dataEnd]
+
+	if metadata.IPVersion == 6 {
+		node := uint32(0)
+		depth := 0
+		for ; depth < 96 && node < metadata.NodeCount; depth++ {
+			node, err = r.readRecord(node, 0)
+			if err != nil {
+				return nil, err
+			}
+		}
+		r.ipv4Start = node
+		r.ipv4Depth = depth
+	}
+
+	return r, nil
+}
+
+// parseMMDBMetadata converts the decoded metadata map into MMDBMetadata.
+func parseMMDBMetadata(raw any) (MMDBMetadata, error) {
+	m, ok := raw.(map[string]any)
+	if !ok {
+		return MMDBMetadata{}, fmt.Errorf("%w: metadata is not a map", ErrInvalidMMDB)
+	}
+
+	var md MMDBMetadata
+
+	nodeCount, ok := mmdbUint(m["node_count"])
+	if !ok || nodeCount > math.MaxUint32 {
+		return MMDBMetadata{}, fmt.Errorf("%w: missing node_count", ErrInvalidMMDB)
+	}
+	md.NodeCount = uint32(nodeCount)
+
+	recordSize, ok := mmdbUint(m["record_size"])
+	if !ok || recordSize > math.MaxUint16 {
+		return MMDBMetadata{}, fmt.Errorf("%w: missing record_size", ErrInvalidMMDB)
+	}
+	md.RecordSize = uint16(recordSize)
+
+	ipVersion, ok := mmdbUint(m["ip_version"])
+	if !ok || (ipVersion != 4 && ipVersion != 6) {
+		return MMDBMetadata{}, fmt.Errorf("%w: invalid ip_version", ErrInvalidMMDB)
+	}
+	md.IPVersion = uint16(ipVersion)
+
+	md.DatabaseType, _ = m["database_type"].(string)
+
+	if major, ok := mmdbUint(m["binary_format_major_version"]); ok {
+		md.BinaryFormatMajorVersion = uint16(major)
+	}
+	if minor, ok := mmdbUint(m["binary_format_minor_version"]); ok {
+		md.BinaryFormatMinorVersion = uint16(minor)
+	}
+	md.BuildEpoch, _ = mmdbUint(m["build_epoch"])
+
+	if languages, ok := m["languages"].([]any); ok {
+		for _, l := range languages {
+			if s, ok := l.(string); ok {
+				md.Languages = append(md.Languages, s)
+			}
+		}
+	}
+
+	if description, ok := m["description"].(map[string]any); ok {
+		md.Description = make(map[string]string, len(description))
+		for k, v := range description {
+			if s, ok := v.(string); ok {
+				md.Description[k] = s
+			}
+		}
+	}
+
+	return md, nil
+}
+
+// mmdbUint returns v as a uint64 if it is a decoded unsigned integer.
+func mmdbUint(v any) (uint64, bool) {
+	switch n := v.(type) {
+	case uint64:
+		return n, true
+	case *big.Int:
+		if n.IsUint64() {
+			return n.Uint64(), true
+		}
+	}
+	return 0, false
+}
+
+// readRecord returns the left (bit 0) or right (bit 1) record of node.
+func (r *mmdbReader) readRecord(node uint32, bit int) (uint32, error) {
+	off := int(node) * r.nodeSize
+	if off+r.nodeSize > r.treeSize {
+		return 0, fmt.Errorf("%w: node %d out of range", ErrInvalidMMDB, node)
+	}
+	b := r.buf[off : off+r.nodeSize]
+
+	switch r.metadata.RecordSize {
+	case 24:
+		b = b[bit*3:]
+		return uint32(b[0])<<16 | uint32(b[1])<<8 | uint32(b[2]), nil
+	case 28:
+		if bit == 0 {
+			return uint32(b[3]&0xf0)<<20 | uint32(b[0])<<16 | uint32(b[1])<<8 | uint32(b[2]), nil
+		}
+		return uint32(b[3]&0x0f)<<24 | uint32(b[4])<<16 | uint32(b[5])<<8 | uint32(b[6]), nil
+	default:
+		return binary.BigEndian.Uint32(b[bit*4:]), nil
+	}
+}
+
+// lookup walks the search tree for addr and returns the network containing
+// it and the decoded data record. ok is false when the address is not in
+// the database.
+func (r *mmdbReader) lookup(addr netip.Addr) (netip.Prefix, any, bool, error) {
+	addr = addr.Unmap().WithZone("")
+
+	var ipBytes []byte
+	node := uint32(0)
+	depth := 0
+
+	if addr.Is4() {
+		b := addr.As4()
+		ipBytes = b[:]
+		if r.metadata.IPVersion == 6 {
+			node = r.ipv4Start
+			depth = r.ipv4Depth
+		}
+	} else {
+		if r.metadata.IPVersion == 4 {
+			return netip.Prefix{}, nil, false, nil
+		}
+		b := addr.As16()
+		ipBytes = b[:]
+	}
+
+	bitCount := len(ipBytes) * 8
+	i := 0
+	for ; i < bitCount && node < r.metadata.NodeCount; i++ {
+		bit := int(ipBytes[i/8]>>(7-uint(i%8))) & 1
+
+		var err error
+		node, err = r.readRecord(node, bit)
+		if err != nil {
+			return netip.Prefix{}, nil, false, err
+		}
+	}
+
+	if node == r.metadata.NodeCount {
+		return netip.Prefix{}, nil, false, nil
+	}
+	if node < r.metadata.NodeCount {
+		return netip.Prefix{}, nil, false, fmt.Errorf("%w: search tree deeper than address", ErrInvalidMMDB)
+	}
+
+	prefixLen := i
+	if addr.Is4() && r.metadata.IPVersion == 6 {
+		prefixLen = i + depth - 96
+		if depth < 96 {
+			// The IPv4 subtree ended early, inside ::/96.
+			prefixLen = 0
+		}
+	}
+
+	network, err := addr.Prefix(prefixLen)
+	if err != nil {
+		return netip.Prefix{}, nil, false, err
+	}
+
+	offset := int(node-r.metadata.NodeCount) - mmdbDataSeparatorSize
+	if offset < 0 || offset >= len(r.data) {
+		return netip.Prefix{}, nil, false, fmt.Errorf("%w: data pointer out of range", ErrInvalidMMDB)
+	}
+
+	d := mmdbDecoder{buf: r.data}
+	value, _, err := d.decode(offset, 0)
+	if err != nil {
+		return netip.Prefix{}, nil, false, err
+	}
+
+	return network, value, true, nil
+}
+
+// mmdbDecoder decodes values from a MaxMind DB data section. Pointers are
+// relative to the start of buf.
+type mmdbDecoder struct {
+	buf []byte
+}
+
+// decode decodes the value at offset and returns it with the offset of the
+// following value.
+func (d mmdbDecoder) decode(offset, depth int) (any, int, error) {
+	if depth > mmdbMaxDepth {
+		return nil, 0, fmt.Errorf("%w: data nested too deeply", ErrInvalidMMDB)
+	}
+
+	if offset >= len(d.buf) {
+		return nil, 0, fmt.Errorf("%w: unexpected end of data", ErrInvalidMMDB)
+	}
+
+	ctrl := d.buf[offset]
+	offset++
+	typ := int(ctrl >> 5)
+
+	if typ == mmdbPointer {
+		target, next, err := d.decodePointer(ctrl, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		value, _, err := d.decode(target, depth+1)
+		return value, next, err
+	}
+
+	if typ == mmdbExtended {
+		if offset >= len(d.buf) {
+			return nil, 0, fmt.Errorf("%w: unexpected end of data", ErrInvalidMMDB)
+		}
+		typ = 7 + int(d.buf[offset])
+		offset++
+	}
+
+	size, offset, err := d.decodeSize(ctrl, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	switch typ {
+	case mmdbMap:
+		m := make(map[string]any, min(size, len(d.buf)))
+		for range size {
+			key, next, err := d.decode(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			k, ok := key.(string)
+			if !ok {
+				return nil, 0, fmt.Errorf("%w: map key is not a string", ErrInvalidMMDB)
+			}
+
+			value, next, err := d.decode(next, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			m[k] = value
+			offset = next
+		}
+		return m, offset, nil
+
+	case mmdbArray:
+		a := make([]any, 0, min(size, len(d.buf)))
+		for range size {
+			value, next, err := d.decode(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			a = append(a, value)
+			offset = next
+		}
+		return a, offset, nil
+
+	case mmdbBool:
+		if size > 1 {
+			return nil, 0, fmt.Errorf("%w: invalid boolean size %d", ErrInvalidMMDB, size)
+		}
+		return size == 1, offset, nil
+
+	case mmdbEndMarker, mmdbContainer:
+		return nil, offset, nil
+	}
+
+	if offset+size > len(d.buf) {
+		return nil, 0, fmt.Errorf("%w: unexpected end of data", ErrInvalidMMDB)
+	}
+	b := d.buf[offset : offset+size]
+	next := offset + size
+
+	switch typ {
+	case mmdbString:
+		return string(b), next, nil
+
+	case mmdbBytes:
+		return bytes.Clone(b), next, nil
+
+	case mmdbDouble:
+		if size != 8 {
+			return nil, 0, fmt.Errorf("%w: invalid double size %d", ErrInvalidMMDB, size)
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), next, nil
+
+	case mmdbFloat:
+		if size != 4 {
+			return nil, 0, fmt.Errorf("%w: invalid float size %d", ErrInvalidMMDB, size)
+		}
+		return math.Float32frombits(binary.BigEndian.Uint32(b)), next, nil
+
+	case mmdbUint16, mmdbUint32, mmdbUint64:
+		maxSize := 8
+		switch typ {
+		case mmdbUint16:
+			maxSize = 2
+		case mmdbUint32:
+			maxSize = 4
+		}
+		if size > maxSize {
+			return nil, 0, fmt.Errorf("%w: invalid integer size %d", ErrInvalidMMDB, size)
+		}
+		var n uint64
+		for _, c := range b {
+			n = n<<8 | uint64(c)
+		}
+		return n, next, nil
+
+	case mmdbInt32:
+		if size > 4 {
+			return nil, 0, fmt.Errorf("%w: invalid integer size %d", ErrInvalidMMDB, size)
+		}
+		var n uint32
+		for _, c := range b {
+			n = n<<8 | uint32(c)
+		}
+		return int64(int32(n)), next, nil
+
+	case mmdbUint128:
+		if size > 16 {
+			return nil, 0, fmt.Errorf("%w: invalid integer size %d", ErrInvalidMMDB, size)
+		}
+		return new(big.Int).SetBytes(b), next, nil
+	}
+
+	return nil, 0, fmt.Errorf("%w: unknown data type %d", ErrInvalidMMDB, typ)
+}
+
+// decodeSize decodes the payload size from the control byte and any
+// following size bytes.
+func (d mmdbDecoder) decodeSize(ctrl byte, offset int) (int, int, error) {
+	size := int(ctrl & 0x1f)
+	if size < 29 {
+		return size, offset, nil
+	}
+
+	n := size - 28
+	if offset+n > len(d.buf) {
+		return 0, 0, fmt.Errorf("%w: unexpected end of data", ErrInvalidMMDB)
+	}
+
+	extra := 0
+	for _, c := range d.buf[offset : offset+n] {
+		extra = extra<<8 | int(c)
+	}
+
+	switch size {
+	case 29:
+		size = 29 + extra
+	case 30:
+		size = 285 + extra
+	default:
+		size = 65821 + extra
+	}
+
+	return size, offset + n, nil
+}
+
+// decodePointer decodes a pointer and returns its target offset and the
+// offset following the pointer.
+func (d mmdbDecoder) decodePointer(ctrl byte, offset int) (int, int, error) {
+	n := int((ctrl>>3)&0x3) + 1
+	if offset+n > len(d.buf) {
+		return 0, 0, fmt.Errorf("%w: unexpected end of data", ErrInvalidMMDB)
+	}
+	b := d.buf[offset : offset+n]
+
+	var target int
+	switch n {
+	case 1:
+		target = int(ctrl&0x7)<<8 | int(b[0])
+	case 2:
+		target = (int(ctrl&0x7)<<16 | int(b[0])<<8 | int(b[1])) + 2048
+	case 3:
+		target = (int(ctrl&0x7)<<24 | int(b[0])<<16 | int(b[1])<<8 | int(b[2])) + 526336
+	default:
+		target = int(binary.BigEndian.Uint32(b))
+	}
+
+	return target, offset + n, nil
+}
+
+// MMDBBackend answers lookups offline from a MaxMind DB (.mmdb) ASN
+// database such as GeoLite2-ASN. It fills ASN and ASName from the
+// autonomous_system_number and autonomous_system_organization fields and
+// reports the matching network as BGPPrefix. The database has no country
+// for the AS, so CountryCode is set to FieldUnavailable.
+type MMDBBackend struct {
+	reader *mmdbReader
+}
+
+// LoadMMDB loads a MaxMind DB file into memory.
+func LoadMMDB(path string) (*MMDBBackend, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewMMDBBackend(buf)
+}
+
+// NewMMDBBackend creates a backend from the contents of a MaxMind DB file.
+func NewMMDBBackend(buf []byte) (*MMDBBackend, error) {
+	reader, err := newMMDBReader(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MMDBBackend{reader: reader}, nil
+}
+
+// Metadata returns the metadata of the loaded database.
+func (b *MMDBBackend) Metadata() MMDBMetadata {
+	return b.reader.metadata
+}
+
+// Query looks up each IP in the database. IPs not in the database are
+// omitted from the results; records that lack an ASN are reported as
+// parse errors.
+func (b *MMDBBackend) Query(_ context.Context, ips []string) ([]Result, []ParseError, error) {
+	var results []Result
+	var parseErrs []ParseError
+
+	for _, ip := range ips {
+		addr, err := netip.ParseAddr(ip)
+		if err != nil {
+			continue
+		}
+
+		network, record, ok, err := b.reader.lookup(addr)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !ok {
+			continue
+		}
+
+		result, err := mmdbRecordToResult(record)
+		if err != nil {
+			parseErrs = append(parseErrs, ParseError{Line: ip, Err: err})
+			continue
+		}
+
+		result.IP = ip
+		result.BGPPrefix = network.String()
+		results = append(results, result)
+	}
+
+	return results, parseErrs, nil
+}
+
+// mmdbRecordToResult extracts the ASN fields from a decoded record.
+func mmdbRecordToResult(record any) (Result, error) {
+	m, ok := record.(map[string]any)
+	if !ok {
+		return Result{}, fmt.Errorf("%w: record is not a map", ErrInvalidMMDB)
+	}
+
+	asn, ok := mmdbUint(m["autonomous_system_number"])
+	if !ok || asn > math.MaxUint32 {
+		return Result{}, fmt.Errorf("%w: record has no autonomous_system_number", ErrInvalidMMDB)
+	}
+
+	name, _ := m["autonomous_system_organization"].(string)
+
+	return Result{
+		ASN:         int(asn),
+		ASNs:        []uint32{uint32(asn)},
+		CountryCode: FieldUnavailable,
+		ASName:      name,
+	}, nil
+}
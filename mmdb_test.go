@@ -0,0 +1,384 @@
+package cymruasn
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"runtime"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// mmdbTestWriter builds small MaxMind DB files for tests.
+type mmdbTestWriter struct {
+	ipVersion  int
+	recordSize int
+	// nodes holds each node's left and right records: 0 means empty
+	// (no node ever points back at the root), a positive value is a node
+	// index and a negative value -(offset+1) points into the data section.
+	nodes [][2]int
+	data  []byte
+}
+
+func newMMDBTestWriter(ipVersion, recordSize int) *mmdbTestWriter {
+	return &mmdbTestWriter{
+		ipVersion:  ipVersion,
+		recordSize: recordSize,
+		nodes:      [][2]int{{}},
+	}
+}
+
+// insert maps network to the data section value at offset.
+func (w *mmdbTestWriter) insert(network string, offset int) {
+	p := netip.MustParsePrefix(network)
+	addr := p.Addr()
+	bits := p.Bits()
+
+	var ip []byte
+	if w.ipVersion == 6 && addr.Is4() {
+		b := addr.As16()
+		// IPv4 networks live in the ::/96 subtree, not ::ffff:0:0/96.
+		clear(b[10:12])
+		ip = b[:]
+		bits += 96
+	} else {
+		ip = addr.AsSlice()
+	}
+
+	node := 0
+	for i := range bits {
+		bit := int(ip[i/8]>>(7-uint(i%8))) & 1
+		if i == bits-1 {
+			w.nodes[node][bit] = -(offset + 1)
+			return
+		}
+		if w.nodes[node][bit] <= 0 {
+			w.nodes = append(w.nodes, [2]int{})
+			w.nodes[node][bit] = len(w.nodes) - 1
+		}
+		node = w.nodes[node][bit]
+	}
+}
+
+// add appends an encoded value to the data section and returns its offset.
+func (w *mmdbTestWriter) add(encoded []byte) int {
+	offset := len(w.data)
+	w.data = append(w.data, encoded...)
+	return offset
+}
+
+func (w *mmdbTestWriter) bytes() []byte {
+	nodeCount := len(w.nodes)
+
+	var buf bytes.Buffer
+	for _, n := range w.nodes {
+		var records [2]uint32
+		for i, v := range n {
+			switch {
+			case v == 0:
+				records[i] = uint32(nodeCount)
+			case v > 0:
+				records[i] = uint32(v)
+			default:
+				records[i] = uint32(nodeCount + mmdbDataSeparatorSize - v - 1)
+			}
+		}
+
+		switch w.recordSize {
+		case 24:
+			for _, r := range records {
+				buf.Write([]byte{byte(r >> 16), byte(r >> 8), byte(r)})
+			}
+		case 28:
+			l, r := records[0], records[1]
+			buf.Write([]byte{
+				byte(l >> 16), byte(l >> 8), byte(l),
+				byte((l>>24)&0x0f)<<4 | byte((r>>24)&0x0f),
+				byte(r >> 16), byte(r >> 8), byte(r),
+			})
+		default:
+			for _, r := range records {
+				buf.Write(binary.BigEndian.AppendUint32(nil, r))
+			}
+		}
+	}
+
+	buf.Write(make([]byte, mmdbDataSeparatorSize))
+	buf.Write(w.data)
+	buf.Write(mmdbMetadataMarker)
+	buf.Write(mmdbEncMap(
+		"node_count", mmdbEncUint(mmdbUint32, uint64(nodeCount)),
+		"record_size", mmdbEncUint(mmdbUint16, uint64(w.recordSize)),
+		"ip_version", mmdbEncUint(mmdbUint16, uint64(w.ipVersion)),
+		"database_type", mmdbEncString("Test-ASN"),
+		"languages", mmdbEncArray(mmdbEncString("en")),
+		"description", mmdbEncMap("en", mmdbEncString("test database")),
+		"binary_format_major_version", mmdbEncUint(mmdbUint16, 2),
+		"binary_format_minor_version", mmdbEncUint(mmdbUint16, 0),
+		"build_epoch", mmdbEncUint(mmdbUint64, 1700000000),
+	))
+
+	return buf.Bytes()
+}
+
+func mmdbEncHeader(typ, size int) []byte {
+	var ctrl []byte
+	if typ > 7 {
+		ctrl = []byte{0, byte(typ - 7)}
+	} else {
+		ctrl = []byte{byte(typ << 5)}
+	}
+
+	switch {
+	case size < 29:
+		ctrl[0] |= byte(size)
+	case size < 285:
+		ctrl[0] |= 29
+		ctrl = append(ctrl, byte(size-29))
+	default:
+		ctrl[0] |= 30
+		ctrl = binary.BigEndian.AppendUint16(ctrl, uint16(size-285))
+	}
+
+	return ctrl
+}
+
+func mmdbEncString(s string) []byte {
+	return append(mmdbEncHeader(mmdbString, len(s)), s...)
+}
+
+func mmdbEncUint(typ int, n uint64) []byte {
+	var b []byte
+	for ; n > 0; n >>= 8 {
+		b = append([]byte{byte(n)}, b...)
+	}
+	return append(mmdbEncHeader(typ, len(b)), b...)
+}
+
+func mmdbEncPointer(offset int) []byte {
+	return []byte{byte(mmdbPointer<<5) | byte(offset>>8&0x7), byte(offset)}
+}
+
+func mmdbEncMap(pairs ...any) []byte {
+	b := mmdbEncHeader(mmdbMap, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		b = append(b, mmdbEncString(pairs[i].(string))...)
+		b = append(b, pairs[i+1].([]byte)...)
+	}
+	return b
+}
+
+func mmdbEncArray(values ...[]byte) []byte {
+	b := mmdbEncHeader(mmdbArray, len(values))
+	for _, v := range values {
+		b = append(b, v...)
+	}
+	return b
+}
+
+// buildTestMMDB returns an ASN database with IPv4 and IPv6 networks. The
+// second record refers to the AS name of the first through a pointer.
+func buildTestMMDB(ipVersion, recordSize int) []byte {
+	w := newMMDBTestWriter(ipVersion, recordSize)
+
+	nameOffset := w.add(mmdbEncString("GOOGLE"))
+	google := w.add(mmdbEncMap(
+		"autonomous_system_number", mmdbEncUint(mmdbUint32, 15169),
+		"autonomous_system_organization", mmdbEncPointer(nameOffset),
+	))
+	googleDNS := w.add(mmdbEncMap(
+		"autonomous_system_number", mmdbEncUint(mmdbUint32, 15169),
+		"autonomous_system_organization", mmdbEncPointer(nameOffset),
+	))
+	cloudflare := w.add(mmdbEncMap(
+		"autonomous_system_number", mmdbEncUint(mmdbUint32, 13335),
+		"autonomous_system_organization", mmdbEncString("CLOUDFLARENET"),
+	))
+
+	w.insert("8.8.4.0/22", google)
+	w.insert("8.8.8.0/24", googleDNS)
+	w.insert("1.1.1.0/24", cloudflare)
+	if ipVersion == 6 {
+		w.insert("2001:4860::/32", google)
+	}
+
+	return w.bytes()
+}
+
+func TestMMDBMetadata(t *testing.T) {
+	b, err := NewMMDBBackend(buildTestMMDB(6, 24))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	md := b.Metadata()
+	if md.IPVersion != 6 || md.RecordSize != 24 {
+		t.Errorf("unexpected metadata: %+v", md)
+	}
+	if md.DatabaseType != "Test-ASN" {
+		t.Errorf("expected database type Test-ASN, got %s", md.DatabaseType)
+	}
+	if md.Description["en"] != "test database" {
+		t.Errorf("expected description, got %v", md.Description)
+	}
+	if len(md.Languages) != 1 || md.Languages[0] != "en" {
+		t.Errorf("expected languages [en], got %v", md.Languages)
+	}
+	if md.BuildEpoch != 1700000000 {
+		t.Errorf("expected build epoch 1700000000, got %d", md.BuildEpoch)
+	}
+}
+
+func TestMMDBBackendQuery(t *testing.T) {
+	for _, tc := range []struct {
+		ipVersion  int
+		recordSize int
+	}{
+		{6, 24}, {6, 28}, {6, 32}, {4, 24},
+	} {
+		db := buildTestMMDB(tc.ipVersion, tc.recordSize)
+
+		b, err := NewMMDBBackend(db)
+		if err != nil {
+			t.Fatalf("v%d/%d: unexpected error: %v", tc.ipVersion, tc.recordSize, err)
+		}
+
+		ips := []string{"8.8.8.8", "8.8.4.4", "1.1.1.1", "9.9.9.9", "2001:4860:4860::8888"}
+		results, parseErrs, err := b.Query(context.Background(), ips)
+		if err != nil {
+			t.Fatalf("v%d/%d: unexpected error: %v", tc.ipVersion, tc.recordSize, err)
+		}
+		if len(parseErrs) != 0 {
+			t.Errorf("v%d/%d: unexpected parse errors: %v", tc.ipVersion, tc.recordSize, parseErrs)
+		}
+
+		want := []Result{
+			{IP: "8.8.8.8", ASN: 15169, BGPPrefix: "8.8.8.0/24", ASName: "GOOGLE"},
+			{IP: "8.8.4.4", ASN: 15169, BGPPrefix: "8.8.4.0/22", ASName: "GOOGLE"},
+			{IP: "1.1.1.1", ASN: 13335, BGPPrefix: "1.1.1.0/24", ASName: "CLOUDFLARENET"},
+		}
+		if tc.ipVersion == 6 {
+			want = append(want, Result{IP: "2001:4860:4860::8888", ASN: 15169, BGPPrefix: "2001:4860::/32", ASName: "GOOGLE"})
+		}
+
+		if len(results) != len(want) {
+			t.Fatalf("v%d/%d: expected %d results, got %d", tc.ipVersion, tc.recordSize, len(want), len(results))
+		}
+
+		for i, w := range want {
+			got := results[i]
+			if got.IP != w.IP || got.ASN != w.ASN || got.BGPPrefix != w.BGPPrefix || got.ASName != w.ASName {
+				t.Errorf("v%d/%d: result %d = %+v, want %+v", tc.ipVersion, tc.recordSize, i, got, w)
+			}
+			if got.CountryCode != FieldUnavailable {
+				t.Errorf("v%d/%d: expected unavailable country, got %s", tc.ipVersion, tc.recordSize, got.CountryCode)
+			}
+		}
+	}
+}
+
+func TestMMDBDecode(t *testing.T) {
+	tests := []struct {
+		name  string
+		input []byte
+		want  any
+	}{
+		{"string", mmdbEncString("hello"), "hello"},
+		{"long string", mmdbEncString(string(bytes.Repeat([]byte("x"), 300))), string(bytes.Repeat([]byte("x"), 300))},
+		{"uint16", mmdbEncUint(mmdbUint16, 443), uint64(443)},
+		{"uint64", mmdbEncUint(mmdbUint64, 1<<40), uint64(1 << 40)},
+		{"int32", append(mmdbEncHeader(mmdbInt32, 4), 0xff, 0xff, 0xff, 0xfe), int64(-2)},
+		{"bool", mmdbEncHeader(mmdbBool, 1), true},
+		{"double", append(mmdbEncHeader(mmdbDouble, 8), 0x3f, 0xf0, 0, 0, 0, 0, 0, 0), 1.0},
+		{"float", append(mmdbEncHeader(mmdbFloat, 4), 0x3f, 0x80, 0, 0), float32(1.0)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, next, err := mmdbDecoder{buf: tt.input}.decode(0, 0)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("decode = %v, want %v", got, tt.want)
+			}
+			if next != len(tt.input) {
+				t.Errorf("next offset = %d, want %d", next, len(tt.input))
+			}
+		})
+	}
+
+	u128 := append(mmdbEncHeader(mmdbUint128, 16), bytes.Repeat([]byte{0xff}, 16)...)
+	got, _, err := mmdbDecoder{buf: u128}.decode(0, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 128), big.NewInt(1))
+	if n, ok := got.(*big.Int); !ok || n.Cmp(want) != 0 {
+		t.Errorf("decode uint128 = %v, want %v", got, want)
+	}
+}
+
+func TestMMDBInvalid(t *testing.T) {
+	if _, err := NewMMDBBackend([]byte("not a database")); !errors.Is(err, ErrInvalidMMDB) {
+		t.Errorf("expected ErrInvalidMMDB, got %v", err)
+	}
+
+	db := buildTestMMDB(6, 24)
+	truncated := db[len(db)-40:]
+	if _, err := NewMMDBBackend(truncated); err == nil {
+		t.Error("expected error for truncated database, got nil")
+	}
+
+	if _, _, err := (mmdbDecoder{buf: []byte{0xe3}}).decode(0, 0); err == nil {
+		t.Error("expected error for truncated map, got nil")
+	}
+
+	// A map declaring about 16M entries must fail without allocating for
+	// them up front.
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	if _, _, err := (mmdbDecoder{buf: []byte{0xff, 0xff, 0xff, 0xff}}).decode(0, 0); err == nil {
+		t.Error("expected error for oversized map, got nil")
+	}
+	runtime.ReadMemStats(&after)
+	if n := after.TotalAlloc - before.TotalAlloc; n > 1<<20 {
+		t.Errorf("expected a small allocation for an oversized map, got %d bytes", n)
+	}
+
+	loop := []byte{byte(mmdbPointer << 5), 0}
+	if _, _, err := (mmdbDecoder{buf: loop}).decode(0, 0); err == nil {
+		t.Error("expected error for pointer loop, got nil")
+	}
+}
+
+func TestLoadMMDB(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test-asn.mmdb")
+	if err := os.WriteFile(path, buildTestMMDB(6, 28), 0o644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	b, err := LoadMMDB(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	c := NewClient(WithBackend(b))
+
+	resp, err := c.Lookup(context.Background(), []string{"1.1.1.1", "9.9.9.9"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(resp.Results) != 1 || resp.Results[0].ASName != "CLOUDFLARENET" {
+		t.Errorf("expected 1 result for CLOUDFLARENET, got %+v", resp.Results)
+	}
+
+	if len(resp.Errors) != 1 {
+		t.Errorf("expected 1 error, got %d", len(resp.Errors))
+	}
+}
@@ -4,35 +4,70 @@ import (
 	"bufio"
 	"bytes"
 	"errors"
+	"io"
 	"strconv"
 	"strings"
+	"time"
 )
 
 var (
 	ErrEmptyResponse = errors.New("empty response from server")
 	ErrInvalidFormat = errors.New("invalid response format")
 	ErrLineTooLong   = errors.New("response line exceeded maximum length")
+	ErrNoResult      = errors.New("no result returned for IP")
 )
 
 const maxLineSize = 1024 * 1024 // 1MB max line size
 
+// allocatedLayout is the date format of the Allocated column.
+const allocatedLayout = "2006-01-02"
+
 // parseResponse parses the bulk whois response into Result structs.
 // Response format (pipe-delimited):
 //
 //	Bulk mode; whois.cymru.com [timestamp]
 //	AS      | IP               | BGP Prefix       | CC | AS Name
 //	15169   | 8.8.8.8          | 8.8.8.0/24       | US | GOOGLE, US
+//
+// In verbose mode the registry and allocation date columns are added:
+//
+//	AS      | IP               | BGP Prefix       | CC | Registry | Allocated  | AS Name
+//	15169   | 8.8.8.8          | 8.8.8.0/24       | US | arin     | 1992-12-01 | GOOGLE, US
 func parseResponse(data []byte) ([]Result, []ParseError, error) {
-	if len(data) == 0 {
-		return nil, nil, ErrEmptyResponse
-	}
+	return parseLines(data, parseLine)
+}
 
-	var results []Result
+// parseLines splits a bulk whois response into lines, skips the banner and
+// header lines, and parses each remaining line with parse.
+func parseLines[T any](data []byte, parse func(string) (T, error)) ([]T, []ParseError, error) {
+	var results []T
 	var parseErrors []ParseError
-	scanner := bufio.NewScanner(bytes.NewReader(data))
+
+	err := scanLines(bytes.NewReader(data), parse,
+		func(result T) bool {
+			results = append(results, result)
+			return true
+		},
+		func(parseErr ParseError) bool {
+			parseErrors = append(parseErrors, parseErr)
+			return true
+		},
+	)
+
+	return results, parseErrors, err
+}
+
+// scanLines reads a bulk whois response from r line by line, skips the
+// banner and header lines, and passes each remaining line parsed with parse
+// to onResult, or its parse error to onParseError. Scanning stops early,
+// without an error, when either callback returns false.
+func scanLines[T any](r io.Reader, parse func(string) (T, error), onResult func(T) bool, onParseError func(ParseError) bool) error {
+	scanner := bufio.NewScanner(r)
 	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	empty := true
 
 	for scanner.Scan() {
+		empty = false
 		line := strings.TrimSpace(scanner.Text())
 
 		if line == "" {
@@ -47,57 +82,150 @@ func parseResponse(data []byte) ([]Result, []ParseError, error) {
 			continue
 		}
 
-		result, err := parseLine(line)
+		result, err := parse(line)
 		if err != nil {
-			parseErrors = append(parseErrors, ParseError{Line: line, Err: err})
+			if !onParseError(ParseError{Line: line, Err: err}) {
+				return nil
+			}
 			continue
 		}
 
-		results = append(results, result)
+		if !onResult(result) {
+			return nil
+		}
 	}
 
 	if err := scanner.Err(); err != nil {
 		if errors.Is(err, bufio.ErrTooLong) {
-			return results, parseErrors, ErrLineTooLong
+			return ErrLineTooLong
 		}
-		return results, parseErrors, err
+		return err
+	}
+
+	if empty {
+		return ErrEmptyResponse
 	}
 
-	return results, parseErrors, nil
+	return nil
 }
 
 // isHeaderLine checks if the line is a column header line.
 func isHeaderLine(line string) bool {
 	lower := strings.ToLower(line)
-	return strings.Contains(lower, "as name") || 
+	return strings.Contains(lower, "as name") ||
+		strings.HasPrefix(lower, "peer_as") ||
 		(strings.HasPrefix(lower, "as") && strings.Contains(lower, "| ip"))
 }
 
 // parseLine parses a single pipe-delimited result line.
 // Expected format: AS | IP | BGP Prefix | CC | AS Name
+// The AS column may list several space-separated origin ASNs.
+// Lines with seven or more columns use the verbose format:
+// AS | IP | BGP Prefix | CC | Registry | Allocated | AS Name
 func parseLine(line string) (Result, error) {
-	parts := strings.Split(line, "|")
-	if len(parts) < 2 {
-		return Result{}, ErrInvalidFormat
+	parts, err := splitLine(line)
+	if err != nil {
+		return Result{}, err
 	}
 
-	for i := range parts {
-		parts[i] = strings.TrimSpace(parts[i])
+	var result Result
+
+	if err := parseOrigins(&result, parts[0]); err != nil {
+		return Result{}, err
 	}
 
-	var result Result
+	if err := parseFields(&result, parts); err != nil {
+		return Result{}, err
+	}
 
-	asnStr := parts[0]
+	return result, nil
+}
+
+// parseOrigins parses the AS column, which holds one or more
+// space-separated origin ASNs or "NA".
+func parseOrigins(result *Result, asnStr string) error {
 	if asnStr == "NA" || asnStr == "" {
 		result.ASN = 0
-	} else {
-		asn, err := strconv.Atoi(asnStr)
+		return nil
+	}
+
+	asns, err := parseASNList(asnStr)
+	if err != nil {
+		return err
+	}
+	result.ASNs = asns
+	result.ASN = int(asns[0])
+	result.MOAS = len(result.ASNs) > 1
+
+	return nil
+}
+
+// parsePeerLine parses a single pipe-delimited peer result line. The first
+// column holds a space-separated list of peer ASNs; the remaining columns
+// match parseLine.
+// Expected format: PEER_AS | IP | BGP Prefix | CC | AS Name
+func parsePeerLine(line string) (PeerResult, error) {
+	parts, err := splitLine(line)
+	if err != nil {
+		return PeerResult{}, err
+	}
+
+	peers, err := parseASNList(parts[0])
+	if err != nil {
+		return PeerResult{}, err
+	}
+
+	var result Result
+	if err := parseFields(&result, parts); err != nil {
+		return PeerResult{}, err
+	}
+
+	return PeerResult{
+		IP:          result.IP,
+		Peers:       peers,
+		BGPPrefix:   result.BGPPrefix,
+		CountryCode: result.CountryCode,
+		ASName:      result.ASName,
+		Registry:    result.Registry,
+		Allocated:   result.Allocated,
+	}, nil
+}
+
+// parseASNList parses a space-separated list of ASNs. "NA" and empty
+// values yield an empty list.
+func parseASNList(s string) ([]uint32, error) {
+	if s == "NA" || s == "" {
+		return nil, nil
+	}
+
+	var asns []uint32
+	for _, field := range strings.Fields(s) {
+		asn, err := strconv.ParseUint(field, 10, 32)
 		if err != nil {
-			return Result{}, err
+			return nil, err
 		}
-		result.ASN = asn
+		asns = append(asns, uint32(asn))
+	}
+
+	return asns, nil
+}
+
+// splitLine splits a pipe-delimited line into trimmed columns.
+func splitLine(line string) ([]string, error) {
+	parts := strings.Split(line, "|")
+	if len(parts) < 2 {
+		return nil, ErrInvalidFormat
 	}
 
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+
+	return parts, nil
+}
+
+// parseFields fills in the columns that follow the AS column.
+func parseFields(result *Result, parts []string) error {
 	if len(parts) >= 2 {
 		result.IP = parts[1]
 	}
@@ -110,9 +238,31 @@ func parseLine(line string) (Result, error) {
 		result.CountryCode = parts[3]
 	}
 
+	if len(parts) >= 7 {
+		result.Registry = parts[4]
+
+		allocated, err := parseAllocated(parts[5])
+		if err != nil {
+			return err
+		}
+		result.Allocated = allocated
+
+		result.ASName = parts[6]
+		return nil
+	}
+
 	if len(parts) >= 5 {
 		result.ASName = parts[4]
 	}
 
-	return result, nil
+	return nil
+}
+
+// parseAllocated parses an allocation date column. Empty values yield
+// the zero time.
+func parseAllocated(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(allocatedLayout, s)
 }
@@ -1,7 +1,9 @@
 package cymruasn
 
 import (
+	"slices"
 	"testing"
+	"time"
 )
 
 func TestParseResponse(t *testing.T) {
@@ -95,6 +97,26 @@ NA      | 192.0.2.1        | NA               | ZZ | NA`,
 				}
 			},
 		},
+		{
+			name: "verbose response",
+			input: `Bulk mode; whois.cymru.com [2024-01-15 12:00:00 +0000]
+AS      | IP               | BGP Prefix       | CC | Registry | Allocated  | AS Name
+15169   | 8.8.8.8          | 8.8.8.0/24       | US | arin     | 1992-12-01 | GOOGLE, US`,
+			wantCount: 1,
+			wantErr:   false,
+			checkFunc: func(t *testing.T, results []Result) {
+				if results[0].Registry != "arin" {
+					t.Errorf("expected registry arin, got %s", results[0].Registry)
+				}
+				want := time.Date(1992, 12, 1, 0, 0, 0, 0, time.UTC)
+				if !results[0].Allocated.Equal(want) {
+					t.Errorf("expected allocated %v, got %v", want, results[0].Allocated)
+				}
+				if results[0].ASName != "GOOGLE, US" {
+					t.Errorf("expected AS name GOOGLE, US, got %s", results[0].ASName)
+				}
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -144,6 +166,51 @@ func TestParseLine(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "verbose line",
+			line: "13335 | 1.1.1.1 | 1.1.1.0/24 | AU | apnic | 2011-08-11 | CLOUDFLARENET, US",
+			want: Result{
+				ASN:         13335,
+				IP:          "1.1.1.1",
+				BGPPrefix:   "1.1.1.0/24",
+				CountryCode: "AU",
+				Registry:    "apnic",
+				Allocated:   time.Date(2011, 8, 11, 0, 0, 0, 0, time.UTC),
+				ASName:      "CLOUDFLARENET, US",
+			},
+			wantErr: false,
+		},
+		{
+			name: "verbose line without allocation date",
+			line: "NA | 192.0.2.1 | NA | ZZ | other | | NA",
+			want: Result{
+				IP:          "192.0.2.1",
+				BGPPrefix:   "NA",
+				CountryCode: "ZZ",
+				Registry:    "other",
+				ASName:      "NA",
+			},
+			wantErr: false,
+		},
+		{
+			name:    "invalid allocation date",
+			line:    "15169 | 8.8.8.8 | 8.8.8.0/24 | US | arin | yesterday | GOOGLE, US",
+			wantErr: true,
+		},
+		{
+			name: "multiple origins",
+			line: "15169 36040 | 8.8.8.8 | 8.8.8.0/24 | US | GOOGLE, US",
+			want: Result{
+				ASN:         15169,
+				ASNs:        []uint32{15169, 36040},
+				MOAS:        true,
+				IP:          "8.8.8.8",
+				BGPPrefix:   "8.8.8.0/24",
+				CountryCode: "US",
+				ASName:      "GOOGLE, US",
+			},
+			wantErr: false,
+		},
 		{
 			name:    "invalid - no pipe",
 			line:    "just some text",
@@ -175,6 +242,12 @@ func TestParseLine(t *testing.T) {
 			if got.ASN != tt.want.ASN {
 				t.Errorf("ASN: got %d, want %d", got.ASN, tt.want.ASN)
 			}
+			if tt.want.ASNs != nil && !slices.Equal(got.ASNs, tt.want.ASNs) {
+				t.Errorf("ASNs: got %v, want %v", got.ASNs, tt.want.ASNs)
+			}
+			if got.MOAS != tt.want.MOAS {
+				t.Errorf("MOAS: got %v, want %v", got.MOAS, tt.want.MOAS)
+			}
 			if got.IP != tt.want.IP {
 				t.Errorf("IP: got %s, want %s", got.IP, tt.want.IP)
 			}
@@ -187,6 +260,12 @@ func TestParseLine(t *testing.T) {
 			if got.ASName != tt.want.ASName {
 				t.Errorf("ASName: got %s, want %s", got.ASName, tt.want.ASName)
 			}
+			if got.Registry != tt.want.Registry {
+				t.Errorf("Registry: got %s, want %s", got.Registry, tt.want.Registry)
+			}
+			if !got.Allocated.Equal(tt.want.Allocated) {
+				t.Errorf("Allocated: got %v, want %v", got.Allocated, tt.want.Allocated)
+			}
 		})
 	}
 }
@@ -0,0 +1,78 @@
+package cymruasn
+
+import (
+	"context"
+	"fmt"
+	"net"
+)
+
+// LookupPeers performs a bulk lookup of the upstream peer ASNs for the given
+// IP addresses against the peer whois server. Team Cymru's peer service only
+// answers for IPv4 addresses, so IPv6 addresses are reported as lookup
+// errors without being queried.
+// It returns a PeerResponse containing successful results and any lookup
+// errors. The function returns a non-nil error only for connection-level
+// failures.
+func (c *Client) LookupPeers(ctx context.Context, ips []string) (*PeerResponse, error) {
+	if len(ips) == 0 {
+		return &PeerResponse{}, nil
+	}
+
+	validIPs, invalidErrs := c.validateIPs(ips)
+	validIPs, v6Errs := splitIPv6(validIPs)
+	invalidErrs = append(invalidErrs, v6Errs...)
+
+	if len(validIPs) == 0 {
+		return &PeerResponse{Errors: invalidErrs}, nil
+	}
+
+	request := c.buildRequest(validIPs)
+
+	if err := c.waitRateLimit(ctx, len(validIPs)); err != nil {
+		return nil, err
+	}
+
+	response, err := c.fetch(ctx, net.JoinHostPort(c.peerServer, c.port), request)
+	if err != nil {
+		return nil, err
+	}
+
+	results, parseErrs, err := parseLines(response, parsePeerLine)
+	if err != nil {
+		return nil, err
+	}
+
+	returned := make([]string, len(results))
+	for i, r := range results {
+		returned[i] = r.IP
+	}
+
+	lookupErrs := missingIPs(validIPs, returned)
+	allErrors := append(invalidErrs, lookupErrs...)
+
+	return &PeerResponse{
+		Results:     results,
+		Errors:      allErrors,
+		ParseErrors: parseErrs,
+	}, nil
+}
+
+// splitIPv6 returns the IPv4 addresses among ips and an error for each IPv6
+// address, which the peer service does not answer for.
+func splitIPv6(ips []string) ([]string, []LookupError) {
+	var v4 []string
+	var errs []LookupError
+
+	for _, ip := range ips {
+		if net.ParseIP(ip).To4() == nil {
+			errs = append(errs, LookupError{
+				IP:  ip,
+				Err: fmt.Errorf("peer lookups only support IPv4: %s", ip),
+			})
+			continue
+		}
+		v4 = append(v4, ip)
+	}
+
+	return v4, errs
+}
@@ -0,0 +1,129 @@
+package cymruasn
+
+import (
+	"context"
+	"net"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParseASNList(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    []uint32
+		wantErr bool
+	}{
+		{"701 1239 3549", []uint32{701, 1239, 3549}, false},
+		{"3356", []uint32{3356}, false},
+		{"  174   3356 ", []uint32{174, 3356}, false},
+		{"NA", nil, false},
+		{"", nil, false},
+		{"701 x", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := parseASNList(tt.input)
+
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected error, got nil")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("parseASNList(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePeerLine(t *testing.T) {
+	got, err := parsePeerLine("701 1239 3549 3561 7132 | 4.2.101.1 | 4.0.0.0/9 | US | arin | 1992-12-01 | LEVEL3, US")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !slices.Equal(got.Peers, []uint32{701, 1239, 3549, 3561, 7132}) {
+		t.Errorf("unexpected peers: %v", got.Peers)
+	}
+	if got.IP != "4.2.101.1" {
+		t.Errorf("IP: got %s, want 4.2.101.1", got.IP)
+	}
+	if got.BGPPrefix != "4.0.0.0/9" {
+		t.Errorf("BGPPrefix: got %s, want 4.0.0.0/9", got.BGPPrefix)
+	}
+	if got.Registry != "arin" {
+		t.Errorf("Registry: got %s, want arin", got.Registry)
+	}
+	if got.ASName != "LEVEL3, US" {
+		t.Errorf("ASName: got %s, want LEVEL3, US", got.ASName)
+	}
+
+	if _, err := parsePeerLine("701 foo | 4.2.101.1"); err == nil {
+		t.Error("expected error for invalid peer ASN, got nil")
+	}
+}
+
+func TestLookupPeersWithMockServer(t *testing.T) {
+	mockResponse := `Bulk mode; peer.whois.cymru.com [2024-01-15 12:00:00 +0000]
+PEER_AS | IP               | BGP Prefix       | CC | AS Name
+701 1239 3549 | 4.2.101.1  | 4.0.0.0/9        | US | LEVEL3, US
+`
+	server, port := startMockServer(t, mockResponse)
+
+	c := NewClient(
+		WithPeerServer(server),
+		WithPort(port),
+		WithTimeout(5*time.Second),
+	)
+
+	resp, err := c.LookupPeers(context.Background(), []string{"4.2.101.1", "8.8.8.8", "bogus"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(resp.Results) != 1 {
+		t.Fatalf("expected 1 result, got %d", len(resp.Results))
+	}
+
+	if !slices.Equal(resp.Results[0].Peers, []uint32{701, 1239, 3549}) {
+		t.Errorf("unexpected peers: %v", resp.Results[0].Peers)
+	}
+
+	if len(resp.Errors) != 2 {
+		t.Errorf("expected 2 errors, got %d", len(resp.Errors))
+	}
+}
+
+func TestLookupPeersSkipsIPv6(t *testing.T) {
+	// Nothing listens on the peer server, so any query fails the lookup.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to create listener: %v", err)
+	}
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+	listener.Close()
+
+	c := NewClient(
+		WithPeerServer("127.0.0.1"),
+		WithPort(port),
+		WithTimeout(5*time.Second),
+	)
+
+	resp, err := c.LookupPeers(context.Background(), []string{"2001:db8::1", "2001:4860:4860::8888"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(resp.Results) != 0 || len(resp.Errors) != 2 {
+		t.Errorf("expected 2 errors and no results, got %+v", resp)
+	}
+}
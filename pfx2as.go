@@ -0,0 +1,146 @@
+package cymruasn
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Pfx2asBackend answers lookups offline from a CAIDA RouteViews prefix to
+// AS (pfx2as) dataset. The dataset carries only prefixes and origin ASNs, so
+// CountryCode and ASName are set to FieldUnavailable.
+type Pfx2asBackend struct {
+	table *prefixTable[[]uint32]
+}
+
+// LoadPfx2as loads a CAIDA routeviews-rv2-*.pfx2as file, plain or
+// gzip-compressed.
+func LoadPfx2as(path string) (*Pfx2asBackend, error) {
+	f, err := openDataFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ReadPfx2as(f)
+}
+
+// ReadPfx2as reads a pfx2as dataset from r. Each line holds a prefix
+// address, a prefix length and the origin AS field, separated by tabs:
+//
+//	1.0.0.0	24	13335
+//
+// Multi-origin prefixes list their origins separated by "_"; AS sets are
+// separated by ",".
+func ReadPfx2as(r io.Reader) (*Pfx2asBackend, error) {
+	r, err := maybeGunzip(r)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &Pfx2asBackend{table: newPrefixTable[[]uint32]()}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		prefix, asns, err := parsePfx2asLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("pfx2as line %d: %w", lineNum, err)
+		}
+
+		b.table.insert(prefix, asns)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// parsePfx2asLine parses a single pfx2as line.
+func parsePfx2asLine(line string) (netip.Prefix, []uint32, error) {
+	fields := strings.Fields(line)
+	if len(fields) != 3 {
+		return netip.Prefix{}, nil, ErrInvalidFormat
+	}
+
+	addr, err := netip.ParseAddr(fields[0])
+	if err != nil {
+		return netip.Prefix{}, nil, err
+	}
+
+	bits, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return netip.Prefix{}, nil, err
+	}
+
+	prefix, err := addr.Prefix(bits)
+	if err != nil {
+		return netip.Prefix{}, nil, err
+	}
+
+	var asns []uint32
+	for _, field := range strings.FieldsFunc(fields[2], func(r rune) bool { return r == '_' || r == ',' }) {
+		asn, err := strconv.ParseUint(field, 10, 32)
+		if err != nil {
+			return netip.Prefix{}, nil, err
+		}
+		asns = append(asns, uint32(asn))
+	}
+
+	if len(asns) == 0 {
+		return netip.Prefix{}, nil, ErrInvalidFormat
+	}
+
+	return prefix, asns, nil
+}
+
+// Len returns the number of prefixes loaded.
+func (b *Pfx2asBackend) Len() int {
+	return b.table.len()
+}
+
+// Query looks up each IP by longest prefix match. IPs not covered by any
+// prefix are omitted from the results.
+func (b *Pfx2asBackend) Query(_ context.Context, ips []string) ([]Result, []ParseError, error) {
+	var results []Result
+
+	for _, ip := range ips {
+		addr, err := netip.ParseAddr(ip)
+		if err != nil {
+			continue
+		}
+
+		prefix, asns, ok := b.table.lookup(addr)
+		if !ok {
+			continue
+		}
+
+		results = append(results, Result{
+			IP:          ip,
+			ASN:         int(asns[0]),
+			ASNs:        slices.Clone(asns),
+			MOAS:        len(asns) > 1,
+			BGPPrefix:   prefix.String(),
+			CountryCode: FieldUnavailable,
+			ASName:      FieldUnavailable,
+		})
+	}
+
+	return results, nil, nil
+}
@@ -0,0 +1,114 @@
+package cymruasn
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const testPfx2as = `1.0.0.0	24	13335
+8.0.0.0	9	3356
+8.8.8.0	24	15169
+192.0.2.0	24	64500_64501
+2001:4860::	32	15169
+`
+
+func TestReadPfx2as(t *testing.T) {
+	b, err := ReadPfx2as(strings.NewReader(testPfx2as))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if b.Len() != 5 {
+		t.Errorf("expected 5 prefixes, got %d", b.Len())
+	}
+
+	results, _, err := b.Query(context.Background(), []string{"8.8.8.8", "8.8.4.4", "192.0.2.1", "2001:4860:4860::8888", "9.9.9.9"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(results) != 4 {
+		t.Fatalf("expected 4 results, got %d", len(results))
+	}
+
+	if results[0].ASN != 15169 || results[0].BGPPrefix != "8.8.8.0/24" {
+		t.Errorf("unexpected result for 8.8.8.8: %+v", results[0])
+	}
+	if results[0].CountryCode != FieldUnavailable || results[0].ASName != FieldUnavailable {
+		t.Errorf("expected unavailable markers, got %+v", results[0])
+	}
+
+	if results[1].ASN != 3356 || results[1].BGPPrefix != "8.0.0.0/9" {
+		t.Errorf("unexpected result for 8.8.4.4: %+v", results[1])
+	}
+
+	if !results[2].MOAS || !slices.Equal(results[2].ASNs, []uint32{64500, 64501}) {
+		t.Errorf("expected MOAS result, got %+v", results[2])
+	}
+
+	if results[3].BGPPrefix != "2001:4860::/32" {
+		t.Errorf("unexpected result for IPv6: %+v", results[3])
+	}
+}
+
+func TestReadPfx2asInvalid(t *testing.T) {
+	tests := []string{
+		"1.0.0.0\t24",
+		"1.0.0.0\t33\t13335",
+		"not-an-ip\t24\t13335",
+		"1.0.0.0\t24\tAS13335",
+	}
+
+	for _, input := range tests {
+		if _, err := ReadPfx2as(strings.NewReader(input)); err == nil {
+			t.Errorf("expected error for %q, got nil", input)
+		}
+	}
+
+	if _, err := ReadPfx2as(strings.NewReader("1.0.0.0\t24")); !errors.Is(err, ErrInvalidFormat) {
+		t.Errorf("expected ErrInvalidFormat for a short line, got %v", err)
+	}
+}
+
+func TestLoadPfx2asGzip(t *testing.T) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write([]byte(testPfx2as)); err != nil {
+		t.Fatalf("failed to compress: %v", err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("failed to compress: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "routeviews-rv2-20240101-1200.pfx2as.gz")
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	b, err := LoadPfx2as(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	c := NewClient(WithBackend(b))
+
+	resp, err := c.Lookup(context.Background(), []string{"1.0.0.1", "9.9.9.9"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(resp.Results) != 1 || resp.Results[0].ASN != 13335 {
+		t.Errorf("expected 1 result for AS13335, got %+v", resp.Results)
+	}
+
+	if len(resp.Errors) != 1 || resp.Errors[0].IP != "9.9.9.9" {
+		t.Errorf("expected 1 error for 9.9.9.9, got %+v", resp.Errors)
+	}
+}
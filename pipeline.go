@@ -0,0 +1,128 @@
+package cymruasn
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"iter"
+	"strings"
+	"time"
+)
+
+// DefaultFlushInterval is the default time LookupSeq waits for a batch to
+// fill before querying it anyway.
+const DefaultFlushInterval = time.Second
+
+// pipelineBuffer is the number of input IPs LookupSeq reads ahead while a
+// batch is being queried.
+const pipelineBuffer = 1024
+
+// LookupSeq looks up the IPs produced by ips, which may be unbounded, and
+// yields results and errors as LookupStream does. IPs are collected into
+// batches of the client's batch size; a batch is queried when it is full,
+// when the flush interval passes without it filling, or when ips ends.
+// Input is read ahead while a batch is being queried.
+//
+// Duplicate IPs are only removed within a batch. If ctx is done, its error
+// is yielded last. A producer blocked waiting for input is not interrupted
+// when iteration stops, so ips should end or be unblocked by its owner.
+func (c *Client) LookupSeq(ctx context.Context, ips iter.Seq[string]) iter.Seq2[Result, error] {
+	return func(yield func(Result, error) bool) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		input := make(chan string, pipelineBuffer)
+
+		go func() {
+			defer close(input)
+			for ip := range ips {
+				select {
+				case input <- ip:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+
+		var batch []string
+		timer := time.NewTimer(c.flushInterval)
+		timer.Stop()
+
+		flush := func() bool {
+			timer.Stop()
+			for r, err := range c.LookupStream(ctx, batch) {
+				if !yield(r, err) {
+					return false
+				}
+			}
+			batch = nil
+			return ctx.Err() == nil
+		}
+
+		for {
+			select {
+			case ip, ok := <-input:
+				if !ok {
+					flush()
+					return
+				}
+
+				if strings.TrimSpace(ip) == "" {
+					continue
+				}
+
+				if len(batch) == 0 {
+					timer.Reset(c.flushInterval)
+				}
+				batch = append(batch, ip)
+
+				if c.batchSize > 0 && len(batch) >= c.batchSize && !flush() {
+					return
+				}
+
+			case <-timer.C:
+				if !flush() {
+					return
+				}
+
+			case <-ctx.Done():
+				yield(Result{}, ctx.Err())
+				return
+			}
+		}
+	}
+}
+
+// LookupReader looks up the IPs read from r, one per line, as LookupSeq
+// does. An error reading r is yielded after the results for the lines read
+// before it.
+func (c *Client) LookupReader(ctx context.Context, r io.Reader) iter.Seq2[Result, error] {
+	return func(yield func(Result, error) bool) {
+		var readErr error
+
+		lines := func(yieldLine func(string) bool) {
+			scanner := bufio.NewScanner(r)
+			scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+
+			for scanner.Scan() {
+				if !yieldLine(scanner.Text()) {
+					return
+				}
+			}
+			readErr = scanner.Err()
+		}
+
+		for result, err := range c.LookupSeq(ctx, lines) {
+			if !yield(result, err) {
+				return
+			}
+		}
+
+		// LookupSeq only finishes without ctx being done once lines has
+		// returned, so readErr is safe to read.
+		if ctx.Err() == nil && readErr != nil {
+			yield(Result{}, fmt.Errorf("failed to read input: %w", readErr))
+		}
+	}
+}
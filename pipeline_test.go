@@ -0,0 +1,127 @@
+package cymruasn
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+func TestLookupReader(t *testing.T) {
+	backend := &stubBackend{
+		results: map[string]Result{
+			"11.0.0.1": {ASN: 64500},
+			"11.0.0.2": {ASN: 64500},
+			"11.0.0.3": {ASN: 64500},
+			"11.0.0.4": {ASN: 64500},
+			"11.0.0.5": {ASN: 64500},
+		},
+	}
+
+	c := NewClient(WithBackend(backend), WithBatchSize(2))
+
+	input := "11.0.0.1\n11.0.0.2\n\n11.0.0.3\n11.0.0.4\n11.0.0.5\n"
+
+	var ips []string
+	for r, err := range c.LookupReader(context.Background(), strings.NewReader(input)) {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		ips = append(ips, r.IP)
+	}
+
+	if len(ips) != 5 {
+		t.Errorf("expected 5 results, got %v", ips)
+	}
+
+	if len(backend.queries) != 3 {
+		t.Errorf("expected 3 batches, got %v", backend.queries)
+	}
+}
+
+func TestLookupReaderFlushInterval(t *testing.T) {
+	backend := &stubBackend{
+		results: map[string]Result{
+			"11.0.0.1": {ASN: 64500},
+		},
+	}
+
+	c := NewClient(WithBackend(backend), WithFlushInterval(10*time.Millisecond))
+
+	pr, pw := io.Pipe()
+	defer pw.Close()
+
+	go func() {
+		_, _ = io.WriteString(pw, "11.0.0.1\n")
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for r, err := range c.LookupReader(ctx, pr) {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if r.IP != "11.0.0.1" {
+			t.Errorf("expected result for 11.0.0.1, got %+v", r)
+		}
+		// The result arrived while the input was still open.
+		break
+	}
+}
+
+func TestLookupReaderError(t *testing.T) {
+	backend := &stubBackend{
+		results: map[string]Result{
+			"11.0.0.1": {ASN: 64500},
+		},
+	}
+
+	c := NewClient(WithBackend(backend))
+
+	errRead := errors.New("read failed")
+	r := io.MultiReader(strings.NewReader("11.0.0.1\n"), iotest.ErrReader(errRead))
+
+	results := 0
+	var last error
+	for _, err := range c.LookupReader(context.Background(), r) {
+		if err == nil {
+			results++
+		}
+		last = err
+	}
+
+	if results != 1 {
+		t.Errorf("expected 1 result, got %d", results)
+	}
+	if !errors.Is(last, errRead) {
+		t.Errorf("expected read error last, got %v", last)
+	}
+}
+
+func TestLookupSeqContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	block := make(chan struct{})
+	defer close(block)
+
+	ips := func(yield func(string) bool) {
+		<-block
+	}
+
+	cancel()
+
+	c := NewClient(WithBackend(&stubBackend{}))
+
+	var last error
+	for _, err := range c.LookupSeq(ctx, ips) {
+		last = err
+	}
+
+	if !errors.Is(last, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", last)
+	}
+}
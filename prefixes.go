@@ -0,0 +1,205 @@
+package cymruasn
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// PrefixRelation describes how the announced BGP prefix relates to the
+// queried prefix or range.
+type PrefixRelation int
+
+const (
+	// RelationUnknown means no announced prefix was returned.
+	RelationUnknown PrefixRelation = iota
+	// RelationEqual means the announced prefix is exactly the input.
+	RelationEqual
+	// RelationCovers means the announced prefix is less specific than the
+	// input and contains all of it.
+	RelationCovers
+	// RelationMoreSpecific means the announced prefix lies inside the input
+	// and does not cover all of it.
+	RelationMoreSpecific
+	// RelationOverlaps means the announced prefix covers the start of an
+	// input range that is not CIDR aligned, but not all of it.
+	RelationOverlaps
+)
+
+// String returns the name of the relation.
+func (r PrefixRelation) String() string {
+	switch r {
+	case RelationEqual:
+		return "equal"
+	case RelationCovers:
+		return "covers"
+	case RelationMoreSpecific:
+		return "more-specific"
+	case RelationOverlaps:
+		return "overlaps"
+	default:
+		return "unknown"
+	}
+}
+
+// PrefixResult contains the lookup result for a single prefix or range.
+type PrefixResult struct {
+	// Input is the prefix or range as given.
+	Input string
+	// Network is the first address of the input, which was queried.
+	Network string
+	// Result is the lookup result for Network.
+	Result Result
+	// Relation describes how Result.BGPPrefix relates to the input.
+	Relation PrefixRelation
+}
+
+// PrefixResponse contains the results of a bulk prefix lookup.
+type PrefixResponse struct {
+	Results []PrefixResult
+	// Errors holds failed lookups; LookupError.IP is the input as given.
+	Errors      []LookupError
+	ParseErrors []ParseError
+}
+
+// LookupPrefixes looks up who routes each of the given prefixes or
+// ranges. Inputs may be CIDR prefixes such as "10.0.0.0/8", inclusive
+// ranges such as "1.2.3.0-1.2.3.255", or single IP addresses. Each input is
+// answered for its first address, and the result reports how the announced
+// prefix relates to the input.
+// The function returns a non-nil error only for connection-level failures.
+func (c *Client) LookupPrefixes(ctx context.Context, inputs []string) (*PrefixResponse, error) {
+	resp := &PrefixResponse{}
+
+	type parsedInput struct {
+		input string
+		r     addrRange
+	}
+
+	var parsed []parsedInput
+	var networks []string
+	seen := make(map[netip.Addr]bool)
+
+	for _, input := range inputs {
+		input = strings.TrimSpace(input)
+		if input == "" {
+			continue
+		}
+
+		r, err := parseAddrRange(input)
+		if err != nil {
+			resp.Errors = append(resp.Errors, LookupError{IP: input, Err: err})
+			continue
+		}
+
+		parsed = append(parsed, parsedInput{input: input, r: r})
+		if !seen[r.start] {
+			seen[r.start] = true
+			networks = append(networks, r.start.String())
+		}
+	}
+
+	if len(networks) == 0 {
+		return resp, nil
+	}
+
+	ipResp, err := c.Lookup(ctx, networks)
+	if err != nil {
+		return nil, err
+	}
+	resp.ParseErrors = ipResp.ParseErrors
+
+	results := make(map[string]Result, len(ipResp.Results))
+	for _, r := range ipResp.Results {
+		results[canonicalIP(r.IP)] = r
+	}
+
+	errs := make(map[string]LookupError, len(ipResp.Errors))
+	for _, e := range ipResp.Errors {
+		errs[canonicalIP(e.IP)] = e
+	}
+
+	for _, p := range parsed {
+		network := p.r.start.String()
+
+		result, ok := results[network]
+		if !ok {
+			e, ok := errs[network]
+			if !ok {
+				e = LookupError{Err: fmt.Errorf("%w: %s", ErrNoResult, network)}
+			}
+			e.IP = p.input
+			resp.Errors = append(resp.Errors, e)
+			continue
+		}
+
+		resp.Results = append(resp.Results, PrefixResult{
+			Input:    p.input,
+			Network:  network,
+			Result:   result,
+			Relation: relatePrefix(result.BGPPrefix, p.r),
+		})
+	}
+
+	return resp, nil
+}
+
+// parseAddrRange parses a CIDR prefix, an inclusive "start-end" range or a
+// single IP address into an address range.
+func parseAddrRange(s string) (addrRange, error) {
+	if strings.Contains(s, "/") {
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return addrRange{}, fmt.Errorf("invalid prefix: %s", s)
+		}
+		return prefixRange(normalizePrefix(prefix)), nil
+	}
+
+	if startStr, endStr, ok := strings.Cut(s, "-"); ok {
+		start, errStart := netip.ParseAddr(strings.TrimSpace(startStr))
+		end, errEnd := netip.ParseAddr(strings.TrimSpace(endStr))
+		if errStart != nil || errEnd != nil {
+			return addrRange{}, fmt.Errorf("invalid range: %s", s)
+		}
+
+		start = start.Unmap().WithZone("")
+		end = end.Unmap().WithZone("")
+		if start.Is4() != end.Is4() || end.Less(start) {
+			return addrRange{}, fmt.Errorf("invalid range: %s", s)
+		}
+
+		return addrRange{start: start, end: end}, nil
+	}
+
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return addrRange{}, fmt.Errorf("invalid prefix or range: %s", s)
+	}
+	addr = addr.Unmap().WithZone("")
+
+	return addrRange{start: addr, end: addr}, nil
+}
+
+// relatePrefix compares an announced prefix with the queried range.
+func relatePrefix(announced string, input addrRange) PrefixRelation {
+	prefix, err := netip.ParsePrefix(announced)
+	if err != nil {
+		return RelationUnknown
+	}
+	a := prefixRange(normalizePrefix(prefix))
+
+	startCmp := a.start.Compare(input.start)
+	endCmp := a.end.Compare(input.end)
+
+	switch {
+	case startCmp == 0 && endCmp == 0:
+		return RelationEqual
+	case startCmp <= 0 && endCmp >= 0:
+		return RelationCovers
+	case startCmp >= 0 && endCmp <= 0:
+		return RelationMoreSpecific
+	default:
+		return RelationOverlaps
+	}
+}
@@ -0,0 +1,125 @@
+package cymruasn
+
+import (
+	"context"
+	"testing"
+)
+
+func TestParseAddrRange(t *testing.T) {
+	tests := []struct {
+		input      string
+		start, end string
+		wantErr    bool
+	}{
+		{"10.0.0.0/8", "10.0.0.0", "10.255.255.255", false},
+		{"10.1.2.3/16", "10.1.0.0", "10.1.255.255", false},
+		{"1.2.3.0-1.2.3.255", "1.2.3.0", "1.2.3.255", false},
+		{"1.2.3.10 - 1.2.4.20", "1.2.3.10", "1.2.4.20", false},
+		{"2001:db8::/48", "2001:db8::", "2001:db8:0:ffff:ffff:ffff:ffff:ffff", false},
+		{"8.8.8.8", "8.8.8.8", "8.8.8.8", false},
+		{"1.2.3.255-1.2.3.0", "", "", true},
+		{"1.2.3.0-2001:db8::", "", "", true},
+		{"10.0.0.0/33", "", "", true},
+		{"not-a-prefix", "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			r, err := parseAddrRange(tt.input)
+
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected error, got nil")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if r.start.String() != tt.start || r.end.String() != tt.end {
+				t.Errorf("parseAddrRange(%s) = %s-%s, want %s-%s", tt.input, r.start, r.end, tt.start, tt.end)
+			}
+		})
+	}
+}
+
+func TestRelatePrefix(t *testing.T) {
+	tests := []struct {
+		announced string
+		input     string
+		want      PrefixRelation
+	}{
+		{"8.8.8.0/24", "8.8.8.0/24", RelationEqual},
+		{"8.0.0.0/9", "8.8.8.0/24", RelationCovers},
+		{"8.8.8.0/24", "8.0.0.0/9", RelationMoreSpecific},
+		{"1.2.3.0/24", "1.2.3.0-1.2.3.255", RelationEqual},
+		{"1.2.3.0/24", "1.2.3.128-1.2.4.10", RelationOverlaps},
+		{"NA", "8.8.8.0/24", RelationUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.announced+" "+tt.input, func(t *testing.T) {
+			r, err := parseAddrRange(tt.input)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := relatePrefix(tt.announced, r); got != tt.want {
+				t.Errorf("relatePrefix(%s, %s) = %s, want %s", tt.announced, tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLookupPrefixes(t *testing.T) {
+	backend := &stubBackend{
+		results: map[string]Result{
+			"8.0.0.0":  {ASN: 3356, BGPPrefix: "8.0.0.0/9"},
+			"8.8.8.0":  {ASN: 15169, BGPPrefix: "8.8.8.0/24"},
+			"1.2.3.0":  {ASN: 64500, BGPPrefix: "1.2.3.0/24"},
+			"1.2.3.10": {ASN: 64500, BGPPrefix: "1.2.3.0/24"},
+		},
+	}
+
+	c := NewClient(WithBackend(backend))
+
+	inputs := []string{"8.0.0.0/8", "8.8.8.0/24", "8.8.8.0/22", "1.2.3.10-1.2.3.20", "9.9.9.0/24", "bogus"}
+	resp, err := c.LookupPrefixes(context.Background(), inputs)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(backend.queries) != 1 || len(backend.queries[0]) != 4 {
+		t.Errorf("expected one query for 4 distinct networks, got %v", backend.queries)
+	}
+
+	want := []struct {
+		input    string
+		asn      int
+		relation PrefixRelation
+	}{
+		{"8.0.0.0/8", 3356, RelationMoreSpecific},
+		{"8.8.8.0/24", 15169, RelationEqual},
+		{"8.8.8.0/22", 15169, RelationMoreSpecific},
+		{"1.2.3.10-1.2.3.20", 64500, RelationCovers},
+	}
+
+	if len(resp.Results) != len(want) {
+		t.Fatalf("expected %d results, got %d", len(want), len(resp.Results))
+	}
+
+	for i, w := range want {
+		got := resp.Results[i]
+		if got.Input != w.input || got.Result.ASN != w.asn || got.Relation != w.relation {
+			t.Errorf("result %d = %s AS%d %s, want %s AS%d %s", i, got.Input, got.Result.ASN, got.Relation, w.input, w.asn, w.relation)
+		}
+	}
+
+	if len(resp.Errors) != 2 {
+		t.Fatalf("expected 2 errors, got %d", len(resp.Errors))
+	}
+	if resp.Errors[0].IP != "bogus" || resp.Errors[1].IP != "9.9.9.0/24" {
+		t.Errorf("unexpected errors: %+v", resp.Errors)
+	}
+}
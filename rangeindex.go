@@ -0,0 +1,108 @@
+package cymruasn
+
+import (
+	"net/netip"
+	"slices"
+)
+
+// addrRange is an inclusive range of addresses within one family.
+type addrRange struct {
+	start netip.Addr
+	end   netip.Addr
+}
+
+// contains reports whether addr lies within the range.
+func (r addrRange) contains(addr netip.Addr) bool {
+	return r.start.Compare(addr) <= 0 && addr.Compare(r.end) <= 0
+}
+
+// prefixRange returns the range of addresses covered by p.
+func prefixRange(p netip.Prefix) addrRange {
+	p = p.Masked()
+	start := p.Addr()
+
+	b := start.AsSlice()
+	for i := p.Bits(); i < len(b)*8; i++ {
+		b[i/8] |= 0x80 >> uint(i%8)
+	}
+	end, _ := netip.AddrFromSlice(b)
+
+	return addrRange{start: start, end: end}
+}
+
+// coveringPrefix returns the smallest prefix that contains the whole range.
+func (r addrRange) coveringPrefix() netip.Prefix {
+	a := r.start.AsSlice()
+	b := r.end.AsSlice()
+
+	bits := 0
+	for i := range a {
+		x := a[i] ^ b[i]
+		if x == 0 {
+			bits += 8
+			continue
+		}
+		for x&0x80 == 0 {
+			bits++
+			x <<= 1
+		}
+		break
+	}
+
+	p, _ := r.start.Prefix(bits)
+	return p
+}
+
+// rangeEntry is an address range with its associated value.
+type rangeEntry[T any] struct {
+	addrRange
+	value T
+}
+
+// rangeIndex answers lookups over non-overlapping address ranges by binary
+// search over ranges sorted by start address. Ranges are added while
+// loading and the index is sorted once before it is used for lookups.
+type rangeIndex[T any] struct {
+	entries []rangeEntry[T]
+}
+
+// add appends a range to the index.
+func (idx *rangeIndex[T]) add(start, end netip.Addr, v T) {
+	idx.entries = append(idx.entries, rangeEntry[T]{
+		addrRange: addrRange{start: start.Unmap(), end: end.Unmap()},
+		value:     v,
+	})
+}
+
+// sort orders the entries by start address. IPv4 sorts before IPv6.
+func (idx *rangeIndex[T]) sort() {
+	slices.SortFunc(idx.entries, func(a, b rangeEntry[T]) int {
+		return a.start.Compare(b.start)
+	})
+}
+
+// lookup returns the range containing addr and its value.
+func (idx *rangeIndex[T]) lookup(addr netip.Addr) (addrRange, T, bool) {
+	addr = addr.Unmap().WithZone("")
+
+	// Find the first entry starting after addr; the candidate precedes it.
+	i, _ := slices.BinarySearchFunc(idx.entries, addr, func(e rangeEntry[T], a netip.Addr) int {
+		if e.start.Compare(a) <= 0 {
+			return -1
+		}
+		return 1
+	})
+
+	if i > 0 && idx.entries[i-1].contains(addr) {
+		e := idx.entries[i-1]
+		return e.addrRange, e.value, true
+	}
+
+	var zero T
+	return addrRange{}, zero, false
+}
+
+// len returns the number of ranges in the index.
+func (idx *rangeIndex[T]) len() int {
+	return len(idx.entries)
+}
@@ -0,0 +1,81 @@
+package cymruasn
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestCoveringPrefix(t *testing.T) {
+	tests := []struct {
+		start, end string
+		want       string
+	}{
+		{"1.0.0.0", "1.0.0.255", "1.0.0.0/24"},
+		{"1.0.1.0", "1.0.3.255", "1.0.0.0/22"},
+		{"10.0.0.5", "10.0.0.5", "10.0.0.5/32"},
+		{"0.0.0.0", "255.255.255.255", "0.0.0.0/0"},
+		{"2001:db8::", "2001:db8:ffff:ffff:ffff:ffff:ffff:ffff", "2001:db8::/32"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.start+"-"+tt.end, func(t *testing.T) {
+			r := addrRange{start: netip.MustParseAddr(tt.start), end: netip.MustParseAddr(tt.end)}
+			if got := r.coveringPrefix().String(); got != tt.want {
+				t.Errorf("coveringPrefix() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrefixRange(t *testing.T) {
+	tests := []struct {
+		prefix     string
+		start, end string
+	}{
+		{"10.0.0.0/8", "10.0.0.0", "10.255.255.255"},
+		{"192.0.2.77/24", "192.0.2.0", "192.0.2.255"},
+		{"8.8.8.8/32", "8.8.8.8", "8.8.8.8"},
+		{"2001:db8::/33", "2001:db8::", "2001:db8:7fff:ffff:ffff:ffff:ffff:ffff"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.prefix, func(t *testing.T) {
+			r := prefixRange(netip.MustParsePrefix(tt.prefix))
+			if r.start.String() != tt.start || r.end.String() != tt.end {
+				t.Errorf("prefixRange(%s) = %s-%s, want %s-%s", tt.prefix, r.start, r.end, tt.start, tt.end)
+			}
+		})
+	}
+}
+
+func TestRangeIndex(t *testing.T) {
+	var idx rangeIndex[string]
+	idx.add(netip.MustParseAddr("2001:db8::"), netip.MustParseAddr("2001:db8::ffff"), "v6")
+	idx.add(netip.MustParseAddr("10.0.0.0"), netip.MustParseAddr("10.0.0.255"), "b")
+	idx.add(netip.MustParseAddr("1.0.0.0"), netip.MustParseAddr("1.0.0.255"), "a")
+	idx.sort()
+
+	tests := []struct {
+		addr   string
+		want   string
+		wantOK bool
+	}{
+		{"1.0.0.0", "a", true},
+		{"1.0.0.255", "a", true},
+		{"1.0.1.0", "", false},
+		{"10.0.0.10", "b", true},
+		{"::ffff:10.0.0.10", "b", true},
+		{"0.0.0.1", "", false},
+		{"2001:db8::1", "v6", true},
+		{"2001:db8::1:0", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			_, got, ok := idx.lookup(netip.MustParseAddr(tt.addr))
+			if ok != tt.wantOK || got != tt.want {
+				t.Errorf("lookup(%s) = %q, %v; want %q, %v", tt.addr, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,185 @@
+package cymruasn
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited is returned instead of waiting when a query would exceed
+// a fail-fast rate limit.
+var ErrRateLimited = errors.New("rate limit exceeded")
+
+// RateLimit limits how often a Client queries. Both limits are token
+// buckets refilled evenly over Interval, so short bursts up to the limit
+// are allowed.
+type RateLimit struct {
+	// Queries is the maximum number of queries per Interval. Zero means no
+	// limit.
+	Queries int
+
+	// IPs is the maximum number of IPs or ASNs looked up per Interval.
+	// Zero means no limit. A single query larger than the limit waits for
+	// the full allowance and then overdraws it.
+	IPs int
+
+	Interval time.Duration
+
+	// FailFast makes a query that would exceed the limit fail with
+	// ErrRateLimited instead of waiting.
+	FailFast bool
+}
+
+// WithRateLimit limits the rate of queries made by the client. The limit is
+// shared by every goroutine using the client. Queries wait for the limit,
+// or until their context is done, unless FailFast is set.
+func WithRateLimit(limit RateLimit) Option {
+	return func(c *Client) {
+		c.limiter = newRateLimiter(limit)
+	}
+}
+
+// clock tells the time and sleeps. It is replaced in tests.
+type clock interface {
+	Now() time.Time
+	Sleep(ctx context.Context, d time.Duration) error
+}
+
+// realClock is the wall clock.
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+// Sleep waits for d or until ctx is done, returning ctx's error in the
+// latter case.
+func (realClock) Sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// tokenBucket holds up to capacity tokens, refilled at rate tokens per
+// nanosecond. Tokens may go negative when a request overdraws the bucket.
+type tokenBucket struct {
+	capacity float64
+	rate     float64
+	tokens   float64
+}
+
+func newTokenBucket(limit int, interval time.Duration) *tokenBucket {
+	if limit <= 0 || interval <= 0 {
+		return nil
+	}
+	return &tokenBucket{
+		capacity: float64(limit),
+		rate:     float64(limit) / float64(interval),
+		tokens:   float64(limit),
+	}
+}
+
+// refill adds the tokens accrued over elapsed.
+func (b *tokenBucket) refill(elapsed time.Duration) {
+	if b == nil {
+		return
+	}
+	b.tokens = min(b.capacity, b.tokens+float64(elapsed)*b.rate)
+}
+
+// delay returns how long to wait before n tokens may be taken. A request
+// larger than the bucket waits until it is full.
+func (b *tokenBucket) delay(n int) time.Duration {
+	if b == nil {
+		return 0
+	}
+	need := min(float64(n), b.capacity) - b.tokens
+	if need <= 0 {
+		return 0
+	}
+	return time.Duration(need / b.rate)
+}
+
+func (b *tokenBucket) take(n int) {
+	if b != nil {
+		b.tokens -= float64(n)
+	}
+}
+
+// rateLimiter enforces a RateLimit across goroutines.
+type rateLimiter struct {
+	mu       sync.Mutex
+	queries  *tokenBucket
+	ips      *tokenBucket
+	failFast bool
+	last     time.Time
+}
+
+func newRateLimiter(limit RateLimit) *rateLimiter {
+	return &rateLimiter{
+		queries:  newTokenBucket(limit.Queries, limit.Interval),
+		ips:      newTokenBucket(limit.IPs, limit.Interval),
+		failFast: limit.FailFast,
+	}
+}
+
+// reserve takes one query and n IPs from the buckets and returns how long
+// the caller must wait before querying. With fail-fast limits it takes
+// nothing and returns ErrRateLimited if the caller would have to wait.
+func (l *rateLimiter) reserve(now time.Time, n int) (time.Duration, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.last.IsZero() {
+		elapsed := now.Sub(l.last)
+		l.queries.refill(elapsed)
+		l.ips.refill(elapsed)
+	}
+	l.last = now
+
+	delay := max(l.queries.delay(1), l.ips.delay(n))
+	if delay > 0 && l.failFast {
+		return 0, ErrRateLimited
+	}
+
+	l.queries.take(1)
+	l.ips.take(n)
+
+	return delay, nil
+}
+
+// cancel returns a reservation that was not used.
+func (l *rateLimiter) cancel(n int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.queries.take(-1)
+	l.ips.take(-n)
+}
+
+// waitRateLimit waits until a query for n IPs or ASNs is allowed by the
+// client's rate limit.
+func (c *Client) waitRateLimit(ctx context.Context, n int) error {
+	if c.limiter == nil {
+		return nil
+	}
+
+	delay, err := c.limiter.reserve(c.clock.Now(), n)
+	if err != nil || delay == 0 {
+		return err
+	}
+
+	if err := c.clock.Sleep(ctx, delay); err != nil {
+		c.limiter.cancel(n)
+		return err
+	}
+
+	return nil
+}
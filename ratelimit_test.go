@@ -0,0 +1,158 @@
+package cymruasn
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock advances instantly when slept on and records each sleep.
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	sleeps []time.Duration
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)}
+}
+
+func (f *fakeClock) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *fakeClock) Sleep(ctx context.Context, d time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sleeps = append(f.sleeps, d)
+	f.now = f.now.Add(d)
+	return nil
+}
+
+func newRateLimitedClient(limit RateLimit, opts ...Option) (*Client, *fakeClock) {
+	opts = append([]Option{WithBackend(&batchBackend{}), WithRateLimit(limit)}, opts...)
+	c := NewClient(opts...)
+	clock := newFakeClock()
+	c.clock = clock
+	return c, clock
+}
+
+func TestRateLimitQueries(t *testing.T) {
+	c, clock := newRateLimitedClient(RateLimit{Queries: 2, Interval: time.Minute})
+
+	for range 4 {
+		if _, err := c.Lookup(context.Background(), []string{"11.0.0.1"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	want := []time.Duration{30 * time.Second, 30 * time.Second}
+	if !slices.Equal(clock.sleeps, want) {
+		t.Errorf("sleeps = %v, want %v", clock.sleeps, want)
+	}
+}
+
+func TestRateLimitIPs(t *testing.T) {
+	c, clock := newRateLimitedClient(RateLimit{IPs: 100, Interval: time.Minute})
+
+	for range 2 {
+		if _, err := c.Lookup(context.Background(), testIPs(60)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	want := []time.Duration{12 * time.Second}
+	if !slices.Equal(clock.sleeps, want) {
+		t.Errorf("sleeps = %v, want %v", clock.sleeps, want)
+	}
+
+	// A query larger than the limit waits for the full allowance.
+	if _, err := c.Lookup(context.Background(), testIPs(150)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if last := clock.sleeps[len(clock.sleeps)-1]; last != time.Minute {
+		t.Errorf("expected to wait 1m for the bucket to refill, waited %v", last)
+	}
+}
+
+func TestRateLimitFailFast(t *testing.T) {
+	c, clock := newRateLimitedClient(RateLimit{Queries: 1, Interval: time.Minute, FailFast: true}, WithRetry(testRetryPolicy))
+
+	if _, err := c.Lookup(context.Background(), []string{"11.0.0.1"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := c.Lookup(context.Background(), []string{"11.0.0.2"}); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("expected %v, got %v", ErrRateLimited, err)
+	}
+
+	if len(clock.sleeps) != 0 {
+		t.Errorf("expected no waiting, got %v", clock.sleeps)
+	}
+
+	clock.now = clock.now.Add(time.Minute)
+
+	if _, err := c.Lookup(context.Background(), []string{"11.0.0.2"}); err != nil {
+		t.Errorf("expected the limit to refill, got %v", err)
+	}
+}
+
+func TestRateLimitShared(t *testing.T) {
+	c, clock := newRateLimitedClient(RateLimit{Queries: 5, Interval: time.Minute})
+
+	var wg sync.WaitGroup
+	for _, ip := range testIPs(10) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := c.Lookup(context.Background(), []string{ip}); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(clock.sleeps) != 5 {
+		t.Errorf("expected 5 of 10 concurrent lookups to wait, got %d", len(clock.sleeps))
+	}
+}
+
+func TestRateLimitContextCanceled(t *testing.T) {
+	c := NewClient(WithBackend(&batchBackend{}), WithRateLimit(RateLimit{Queries: 1, Interval: time.Hour}))
+
+	if _, err := c.Lookup(context.Background(), []string{"11.0.0.1"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if _, err := c.Lookup(ctx, []string{"11.0.0.2"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected %v, got %v", context.DeadlineExceeded, err)
+	}
+
+	// The abandoned query returns its reservation in the background.
+	deadline := time.Now().Add(time.Second)
+	for {
+		c.limiter.mu.Lock()
+		tokens := c.limiter.queries.tokens
+		c.limiter.mu.Unlock()
+
+		if tokens >= 0 && tokens < 0.01 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the cancelled reservation to be returned, have %v tokens", tokens)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,129 @@
+package cymruasn
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"syscall"
+	"time"
+)
+
+// ErrTruncatedResponse is returned when a whois response ends part way
+// through a line, as happens when the server drops the connection.
+var ErrTruncatedResponse = errors.New("truncated response from server")
+
+// RetryPolicy controls how failed queries are retried. Only errors
+// classified as retryable by IsRetryable are retried.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts, including the first.
+	// Values below two disable retries.
+	MaxAttempts int
+
+	// InitialBackoff is the delay before the first retry. Each later delay
+	// is Multiplier times the previous one, up to MaxBackoff.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+
+	// Jitter randomizes each delay by up to this fraction in either
+	// direction, so that clients failing together do not retry together.
+	Jitter float64
+
+	// MaxElapsed stops retrying once the next attempt would start more
+	// than this long after the first. Zero means no limit.
+	MaxElapsed time.Duration
+}
+
+// DefaultRetryPolicy is a retry policy suited to the occasional dropped
+// connection from Team Cymru's servers.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    4,
+	InitialBackoff: 500 * time.Millisecond,
+	MaxBackoff:     10 * time.Second,
+	Multiplier:     2,
+	Jitter:         0.2,
+	MaxElapsed:     time.Minute,
+}
+
+// WithRetry retries failed queries according to policy. By default
+// failed queries are not retried.
+func WithRetry(policy RetryPolicy) Option {
+	return func(c *Client) {
+		c.retry = policy
+	}
+}
+
+// IsRetryable reports whether err is a transient failure worth retrying:
+// a timeout, a reset or refused connection, or an empty or truncated
+// response. Context cancellation, oversized responses and other errors are
+// fatal.
+func IsRetryable(err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return false
+	case errors.Is(err, ErrEmptyResponse),
+		errors.Is(err, ErrTruncatedResponse),
+		errors.Is(err, io.ErrUnexpectedEOF):
+		return true
+	case errors.Is(err, syscall.ECONNRESET),
+		errors.Is(err, syscall.ECONNABORTED),
+		errors.Is(err, syscall.ECONNREFUSED),
+		errors.Is(err, syscall.EPIPE):
+		return true
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return dnsErr.IsTimeout || dnsErr.IsTemporary
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// withRetry calls attempt until it succeeds, fails with an error that is
+// not retryable, or the retry policy or ctx stops it. It returns the number
+// of attempts made and the last error.
+func (c *Client) withRetry(ctx context.Context, attempt func() error) (int, error) {
+	start := c.clock.Now()
+	backoff := c.retry.InitialBackoff
+
+	for n := 1; ; n++ {
+		err := attempt()
+		if err == nil || n >= c.retry.MaxAttempts || !IsRetryable(err) || ctx.Err() != nil {
+			return n, err
+		}
+
+		delay := jitter(backoff, c.retry.Jitter)
+		if c.retry.MaxElapsed > 0 && c.clock.Now().Sub(start)+delay > c.retry.MaxElapsed {
+			return n, err
+		}
+
+		if c.clock.Sleep(ctx, delay) != nil {
+			return n, err
+		}
+
+		backoff = nextBackoff(backoff, c.retry)
+	}
+}
+
+// nextBackoff returns the delay that follows backoff under policy.
+func nextBackoff(backoff time.Duration, policy RetryPolicy) time.Duration {
+	next := time.Duration(float64(backoff) * max(policy.Multiplier, 1))
+	if policy.MaxBackoff > 0 && next > policy.MaxBackoff {
+		return policy.MaxBackoff
+	}
+	return next
+}
+
+// jitter randomizes d by up to the given fraction in either direction.
+func jitter(d time.Duration, fraction float64) time.Duration {
+	if fraction <= 0 {
+		return d
+	}
+	return time.Duration(float64(d) * (1 + fraction*(2*rand.Float64()-1)))
+}
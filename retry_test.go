@@ -0,0 +1,305 @@
+package cymruasn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"empty response", ErrEmptyResponse, true},
+		{"truncated response", ErrTruncatedResponse, true},
+		{"connection reset", fmt.Errorf("failed to read response: %w", &net.OpError{Op: "read", Err: os.NewSyscallError("read", syscall.ECONNRESET)}), true},
+		{"connection refused", fmt.Errorf("failed to connect: %w", &net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}), true},
+		{"timeout", &net.OpError{Op: "read", Err: os.ErrDeadlineExceeded}, true},
+		{"dns timeout", &net.DNSError{Err: "timeout", IsTimeout: true}, true},
+		{"dns not found", &net.DNSError{Err: "no such host", IsNotFound: true}, false},
+		{"canceled", context.Canceled, false},
+		{"too large", ErrResponseTooLarge, false},
+		{"line too long", ErrLineTooLong, false},
+		{"other", errors.New("boom"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsRetryable(tt.err); got != tt.want {
+				t.Errorf("IsRetryable(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+// flakyBackend fails the first failures queries with err and then answers
+// every IP with AS64500.
+type flakyBackend struct {
+	failures int
+	err      error
+	calls    int
+}
+
+func (b *flakyBackend) Query(_ context.Context, ips []string) ([]Result, []ParseError, error) {
+	b.calls++
+	if b.calls <= b.failures {
+		return nil, nil, b.err
+	}
+
+	results := make([]Result, len(ips))
+	for i, ip := range ips {
+		results[i] = Result{IP: ip, ASN: 64500}
+	}
+	return results, nil, nil
+}
+
+var testRetryPolicy = RetryPolicy{
+	MaxAttempts:    3,
+	InitialBackoff: time.Millisecond,
+	MaxBackoff:     5 * time.Millisecond,
+	Multiplier:     2,
+	Jitter:         0.5,
+}
+
+func TestLookupRetries(t *testing.T) {
+	backend := &flakyBackend{failures: 2, err: ErrEmptyResponse}
+	c := NewClient(WithBackend(backend), WithRetry(testRetryPolicy))
+
+	resp, err := c.Lookup(context.Background(), []string{"8.8.8.8"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if resp.Attempts != 3 || len(resp.Results) != 1 {
+		t.Errorf("expected 1 result after 3 attempts, got %d after %d", len(resp.Results), resp.Attempts)
+	}
+}
+
+func TestLookupRetriesExhausted(t *testing.T) {
+	backend := &flakyBackend{failures: 5, err: ErrEmptyResponse}
+	c := NewClient(WithBackend(backend), WithRetry(testRetryPolicy))
+
+	if _, err := c.Lookup(context.Background(), []string{"8.8.8.8"}); !errors.Is(err, ErrEmptyResponse) {
+		t.Errorf("expected %v, got %v", ErrEmptyResponse, err)
+	}
+
+	if backend.calls != 3 {
+		t.Errorf("expected 3 attempts, got %d", backend.calls)
+	}
+}
+
+func TestLookupFatalNotRetried(t *testing.T) {
+	backend := &flakyBackend{failures: 1, err: ErrResponseTooLarge}
+	c := NewClient(WithBackend(backend), WithRetry(testRetryPolicy))
+
+	if _, err := c.Lookup(context.Background(), []string{"8.8.8.8"}); !errors.Is(err, ErrResponseTooLarge) {
+		t.Errorf("expected %v, got %v", ErrResponseTooLarge, err)
+	}
+
+	if backend.calls != 1 {
+		t.Errorf("expected 1 attempt, got %d", backend.calls)
+	}
+}
+
+func TestLookupRetryContextCanceled(t *testing.T) {
+	backend := &flakyBackend{failures: 5, err: ErrEmptyResponse}
+	policy := testRetryPolicy
+	policy.InitialBackoff = time.Hour
+	c := NewClient(WithBackend(backend), WithRetry(policy))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if _, err := c.Lookup(ctx, []string{"8.8.8.8"}); err == nil {
+		t.Error("expected error, got nil")
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the backoff to end with the context, took %v", elapsed)
+	}
+}
+
+func TestLookupRetryMaxElapsed(t *testing.T) {
+	backend := &flakyBackend{failures: 5, err: ErrEmptyResponse}
+	policy := testRetryPolicy
+	policy.MaxAttempts = 10
+	policy.InitialBackoff = 50 * time.Millisecond
+	policy.MaxElapsed = 10 * time.Millisecond
+	policy.Jitter = 0
+	c := NewClient(WithBackend(backend), WithRetry(policy))
+
+	if _, err := c.Lookup(context.Background(), []string{"8.8.8.8"}); err == nil {
+		t.Error("expected error, got nil")
+	}
+
+	if backend.calls != 1 {
+		t.Errorf("expected 1 attempt within the elapsed limit, got %d", backend.calls)
+	}
+}
+
+func TestLookupTruncatedResponse(t *testing.T) {
+	host, port := startMockServer(t, "15169   | 8.8.8.8          | 8.8.8.0/24       | US | GOO")
+	c := NewClient(WithServer(host), WithPort(port), WithTimeout(5*time.Second))
+
+	if _, err := c.Lookup(context.Background(), []string{"8.8.8.8"}); !errors.Is(err, ErrTruncatedResponse) {
+		t.Errorf("expected %v, got %v", ErrTruncatedResponse, err)
+	}
+}
+
+func TestLookupStreamRetries(t *testing.T) {
+	backend := &flakyBackend{failures: 1, err: ErrTruncatedResponse}
+	c := NewClient(WithBackend(backend), WithRetry(testRetryPolicy))
+
+	results := 0
+	for _, err := range c.LookupStream(context.Background(), []string{"8.8.8.8", "1.1.1.1"}) {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		results++
+	}
+
+	if results != 2 || backend.calls != 2 {
+		t.Errorf("expected 2 results after 2 attempts, got %d after %d", results, backend.calls)
+	}
+}
+
+func TestNextBackoff(t *testing.T) {
+	policy := RetryPolicy{Multiplier: 2, MaxBackoff: 300 * time.Millisecond}
+
+	backoff := 100 * time.Millisecond
+	want := []time.Duration{200 * time.Millisecond, 300 * time.Millisecond, 300 * time.Millisecond}
+
+	for i, w := range want {
+		backoff = nextBackoff(backoff, policy)
+		if backoff != w {
+			t.Errorf("step %d: backoff = %v, want %v", i, backoff, w)
+		}
+	}
+
+	for range 100 {
+		if d := jitter(time.Second, 0.2); d < 800*time.Millisecond || d > 1200*time.Millisecond {
+			t.Fatalf("jitter(1s, 0.2) = %v, out of range", d)
+		}
+	}
+}
+
+// startSequenceServer starts a whois server that answers its nth connection
+// with the nth response, repeating the last one once they run out.
+func startSequenceServer(t *testing.T, responses ...string) (string, string) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to create listener: %v", err)
+	}
+	t.Cleanup(func() {
+		if closeErr := listener.Close(); closeErr != nil {
+			t.Logf("failed to close listener: %v", closeErr)
+		}
+	})
+
+	go func() {
+		for n := 0; ; n++ {
+			conn, acceptErr := listener.Accept()
+			if acceptErr != nil {
+				return
+			}
+
+			response := responses[min(n, len(responses)-1)]
+			go func() {
+				defer conn.Close()
+
+				buf := make([]byte, 64*1024)
+				if _, readErr := conn.Read(buf); readErr != nil {
+					return
+				}
+				conn.Write([]byte(response))
+			}()
+		}
+	}()
+
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	return host, port
+}
+
+func TestLookupStreamTruncatedRetried(t *testing.T) {
+	host, port := startSequenceServer(t,
+		"15169   | 8.8.8.8          | 8.8.8.0/24       | US | GOO",
+		"15169   | 8.8.8.8          | 8.8.8.0/24       | US | GOOGLE, US\n",
+	)
+	cache := NewCache(10, time.Hour, time.Hour)
+	c := NewClient(WithServer(host), WithPort(port), WithTimeout(5*time.Second), WithRetry(testRetryPolicy), WithCache(cache))
+
+	var results []Result
+	for r, err := range c.LookupStream(context.Background(), []string{"8.8.8.8"}) {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		results = append(results, r)
+	}
+
+	if len(results) != 1 || results[0].ASName != "GOOGLE, US" {
+		t.Fatalf("expected the complete line from the retry, got %+v", results)
+	}
+
+	if r, ok := cache.get("8.8.8.8", false); !ok || r.ASName != "GOOGLE, US" {
+		t.Errorf("expected the complete result cached, got %+v", r)
+	}
+}
+
+func TestLookupStreamTruncatedNotRetried(t *testing.T) {
+	host, port := startMockServer(t, "15169   | 8.8.8.8          | 8.8.8.0/24       | US | GOO")
+	c := NewClient(WithServer(host), WithPort(port), WithTimeout(5*time.Second))
+
+	for r, err := range c.LookupStream(context.Background(), []string{"8.8.8.8"}) {
+		if !errors.Is(err, ErrTruncatedResponse) {
+			t.Errorf("expected %v, got %+v, %v", ErrTruncatedResponse, r, err)
+		}
+	}
+}
+
+// cutStreamer streams the first line of a multi-origin answer and then
+// fails, as when the connection drops, before answering in full.
+type cutStreamer struct {
+	calls int
+}
+
+func (b *cutStreamer) Query(_ context.Context, _ []string) ([]Result, []ParseError, error) {
+	return nil, nil, errors.New("not used")
+}
+
+func (b *cutStreamer) stream(_ context.Context, ips []string, onResult func(Result) bool, _ func(ParseError) bool) error {
+	b.calls++
+	onResult(Result{IP: "8.8.8.8", ASN: 15169, ASNs: []uint32{15169}})
+	if b.calls == 1 {
+		return ErrTruncatedResponse
+	}
+	onResult(Result{IP: "8.8.8.8", ASN: 3356, ASNs: []uint32{3356}})
+	return nil
+}
+
+func TestLookupStreamRetriesPartialMOAS(t *testing.T) {
+	backend := &cutStreamer{}
+	c := NewClient(WithBackend(backend), WithRetry(testRetryPolicy))
+
+	var results []Result
+	for r, err := range c.LookupStream(context.Background(), []string{"8.8.8.8"}) {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		results = append(results, r)
+	}
+
+	if backend.calls != 2 || len(results) != 1 || !results[0].MOAS {
+		t.Errorf("expected one merged result after 2 attempts, got %+v after %d", results, backend.calls)
+	}
+}
@@ -0,0 +1,109 @@
+package cymruasn
+
+import "net/netip"
+
+// SpecialPurpose describes a special-purpose address block from the IANA
+// IPv4 and IPv6 Special-Purpose Address Registries (RFC 6890) and related
+// RFCs.
+type SpecialPurpose struct {
+	// Prefix is the special-purpose block containing the address.
+	Prefix string
+	// Category names the purpose of the block, such as "private-use",
+	// "shared-address-space", "documentation", "loopback", "link-local",
+	// "multicast" or "reserved".
+	Category string
+}
+
+// specialPurposeBlocks lists the blocks that are not globally routed.
+// Globally reachable entries of the registries, such as AS112 and the
+// 6to4 and Teredo prefixes, are deliberately left out so they are still
+// looked up.
+var specialPurposeBlocks = []struct {
+	prefix   string
+	category string
+}{
+	{"0.0.0.0/8", "this-network"},
+	{"10.0.0.0/8", "private-use"},
+	{"100.64.0.0/10", "shared-address-space"},
+	{"127.0.0.0/8", "loopback"},
+	{"169.254.0.0/16", "link-local"},
+	{"172.16.0.0/12", "private-use"},
+	{"192.0.0.0/24", "ietf-protocol-assignments"},
+	{"192.0.2.0/24", "documentation"},
+	{"192.168.0.0/16", "private-use"},
+	{"198.18.0.0/15", "benchmarking"},
+	{"198.51.100.0/24", "documentation"},
+	{"203.0.113.0/24", "documentation"},
+	{"224.0.0.0/4", "multicast"},
+	{"240.0.0.0/4", "reserved"},
+	{"255.255.255.255/32", "limited-broadcast"},
+
+	{"::/128", "unspecified"},
+	{"::1/128", "loopback"},
+	{"64:ff9b:1::/48", "local-use-translation"},
+	{"100::/64", "discard-only"},
+	{"2001:2::/48", "benchmarking"},
+	{"2001:db8::/32", "documentation"},
+	{"3fff::/20", "documentation"},
+	{"5f00::/16", "segment-routing"},
+	{"fc00::/7", "unique-local"},
+	{"fe80::/10", "link-local"},
+	{"ff00::/8", "multicast"},
+}
+
+// specialPurposeTable indexes specialPurposeBlocks for longest-prefix
+// matching.
+var specialPurposeTable = func() *prefixTable[string] {
+	table := newPrefixTable[string]()
+	for _, b := range specialPurposeBlocks {
+		table.insert(netip.MustParsePrefix(b.prefix), b.category)
+	}
+	return table
+}()
+
+// LookupSpecialPurpose reports whether ip lies in a special-purpose block
+// that is not globally routed, and if so which one. IPv4-mapped IPv6
+// addresses are classified as IPv4.
+func LookupSpecialPurpose(ip string) (SpecialPurpose, bool) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return SpecialPurpose{}, false
+	}
+
+	prefix, category, ok := specialPurposeTable.lookup(addr)
+	if !ok {
+		return SpecialPurpose{}, false
+	}
+
+	return SpecialPurpose{Prefix: prefix.String(), Category: category}, true
+}
+
+// classifyIPs splits validated IPs into those that need a query and local
+// results for those in special-purpose blocks. The local results carry the
+// same placeholder values Team Cymru returns for unrouted space.
+func (c *Client) classifyIPs(ips []string) ([]string, []Result) {
+	if !c.specialPurposeCheck {
+		return ips, nil
+	}
+
+	var query []string
+	var local []Result
+
+	for _, ip := range ips {
+		sp, ok := LookupSpecialPurpose(ip)
+		if !ok {
+			query = append(query, ip)
+			continue
+		}
+
+		local = append(local, Result{
+			IP:             ip,
+			BGPPrefix:      "NA",
+			CountryCode:    "ZZ",
+			ASName:         "NA",
+			SpecialPurpose: &sp,
+		})
+	}
+
+	return query, local
+}
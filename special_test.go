@@ -0,0 +1,114 @@
+package cymruasn
+
+import (
+	"context"
+	"testing"
+)
+
+func TestLookupSpecialPurpose(t *testing.T) {
+	tests := []struct {
+		ip       string
+		prefix   string
+		category string
+		wantOK   bool
+	}{
+		{"10.1.2.3", "10.0.0.0/8", "private-use", true},
+		{"172.31.255.255", "172.16.0.0/12", "private-use", true},
+		{"192.168.1.1", "192.168.0.0/16", "private-use", true},
+		{"100.64.0.1", "100.64.0.0/10", "shared-address-space", true},
+		{"127.0.0.1", "127.0.0.0/8", "loopback", true},
+		{"169.254.169.254", "169.254.0.0/16", "link-local", true},
+		{"192.0.2.1", "192.0.2.0/24", "documentation", true},
+		{"198.51.100.7", "198.51.100.0/24", "documentation", true},
+		{"203.0.113.200", "203.0.113.0/24", "documentation", true},
+		{"198.19.0.1", "198.18.0.0/15", "benchmarking", true},
+		{"239.255.255.250", "224.0.0.0/4", "multicast", true},
+		{"255.255.255.255", "255.255.255.255/32", "limited-broadcast", true},
+		{"250.0.0.1", "240.0.0.0/4", "reserved", true},
+		{"::ffff:10.0.0.1", "10.0.0.0/8", "private-use", true},
+		{"::1", "::1/128", "loopback", true},
+		{"::", "::/128", "unspecified", true},
+		{"fd00::1", "fc00::/7", "unique-local", true},
+		{"fe80::1%eth0", "fe80::/10", "link-local", true},
+		{"ff02::1", "ff00::/8", "multicast", true},
+		{"2001:db8::1", "2001:db8::/32", "documentation", true},
+		{"8.8.8.8", "", "", false},
+		{"192.31.196.1", "", "", false},
+		{"2001:4860:4860::8888", "", "", false},
+		{"2001::1", "", "", false},
+		{"not-an-ip", "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			got, ok := LookupSpecialPurpose(tt.ip)
+			if ok != tt.wantOK {
+				t.Fatalf("LookupSpecialPurpose(%s) ok = %v, want %v", tt.ip, ok, tt.wantOK)
+			}
+			if got.Prefix != tt.prefix || got.Category != tt.category {
+				t.Errorf("LookupSpecialPurpose(%s) = %+v, want %s %s", tt.ip, got, tt.prefix, tt.category)
+			}
+		})
+	}
+}
+
+func TestLookupSpecialPurposeLocal(t *testing.T) {
+	backend := &stubBackend{
+		results: map[string]Result{
+			"8.8.8.8": {ASN: 15169, BGPPrefix: "8.8.8.0/24"},
+		},
+	}
+
+	c := NewClient(WithBackend(backend))
+
+	resp, err := c.Lookup(context.Background(), []string{"10.0.0.1", "8.8.8.8", "fe80::1"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(backend.queries) != 1 || len(backend.queries[0]) != 1 || backend.queries[0][0] != "8.8.8.8" {
+		t.Errorf("expected only 8.8.8.8 to be queried, got %v", backend.queries)
+	}
+
+	if len(resp.Results) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(resp.Results))
+	}
+
+	categories := map[string]string{}
+	for _, r := range resp.Results {
+		if r.SpecialPurpose != nil {
+			categories[r.IP] = r.SpecialPurpose.Category
+		}
+	}
+
+	if categories["10.0.0.1"] != "private-use" || categories["fe80::1"] != "link-local" {
+		t.Errorf("unexpected categories: %v", categories)
+	}
+
+	t.Run("all special", func(t *testing.T) {
+		backend.queries = nil
+
+		resp, err := c.Lookup(context.Background(), []string{"127.0.0.1"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(backend.queries) != 0 {
+			t.Errorf("expected no query, got %v", backend.queries)
+		}
+		if len(resp.Results) != 1 || resp.Results[0].BGPPrefix != "NA" {
+			t.Errorf("unexpected results: %+v", resp.Results)
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		backend.queries = nil
+
+		c := NewClient(WithBackend(backend), WithSpecialPurposeCheck(false))
+		if _, err := c.Lookup(context.Background(), []string{"10.0.0.1"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(backend.queries) != 1 {
+			t.Errorf("expected 10.0.0.1 to be queried, got %v", backend.queries)
+		}
+	})
+}
@@ -0,0 +1,281 @@
+package cymruasn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+)
+
+// resultStreamer is implemented by backends that can pass results on as
+// they arrive instead of returning them all at once.
+type resultStreamer interface {
+	stream(ctx context.Context, ips []string, onResult func(Result) bool, onParseError func(ParseError) bool) error
+}
+
+// stream sends the IPs to the whois servers in a single bulk session and
+// parses the response lines as they are read from the connection. It fails
+// over to the next server only until the first byte has been read, since
+// results passed on cannot be taken back.
+func (b whoisBackend) stream(ctx context.Context, ips []string, onResult func(Result) bool, onParseError func(ParseError) bool) error {
+	c := b.client
+	request := c.buildRequest(ips)
+
+	var err error
+	for _, e := range c.orderedEndpoints() {
+		var read int64
+		read, err = c.streamFrom(ctx, e.addr, request, onResult, onParseError)
+		if err == nil {
+			e.markUp()
+			return nil
+		}
+
+		if !c.failed(ctx, e, err) || read > 0 {
+			return err
+		}
+	}
+
+	return err
+}
+
+// streamFrom streams the response to the request from the whois server at
+// addr, tagging each result with addr. It returns the number of bytes read.
+//
+// Each line is held back until the next one has been read, so that the
+// last line is only passed on once its newline shows it is complete.
+func (c *Client) streamFrom(ctx context.Context, addr string, request []byte, onResult func(Result) bool, onParseError func(ParseError) bool) (int64, error) {
+	conn, err := c.send(ctx, addr, request)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	var held func() bool
+	hold := func(next func() bool) bool {
+		ok := held == nil || held()
+		held = next
+		return ok
+	}
+
+	tag := func(res Result) bool {
+		res.Server = addr
+		return hold(func() bool { return onResult(res) })
+	}
+	parseErr := func(e ParseError) bool {
+		return hold(func() bool { return onParseError(e) })
+	}
+
+	r := &maxSizeReader{r: conn}
+	if err := scanLines(r, parseLine, tag, parseErr); err != nil {
+		return r.read, err
+	}
+
+	if r.read > 0 && r.last != '\n' {
+		return r.read, ErrTruncatedResponse
+	}
+
+	if held != nil {
+		held()
+	}
+
+	return r.read, nil
+}
+
+// LookupStream performs a bulk ASN lookup like Lookup, but yields each
+// result as soon as its line is read from the server instead of collecting
+// the whole response first. Each distinct IP is queried once and large
+// inputs are split into batches, which are queried one after another.
+//
+// The lines for an IP announced by several origins are merged into one
+// result as in Lookup, provided they are adjacent in the response, as Team
+// Cymru's servers send them. A later, non-adjacent line for an IP that was
+// already yielded is dropped rather than yielded as a second result.
+//
+// Errors are yielded with a zero Result. A LookupError reports an IP that
+// is invalid, missing from the response, or in a batch that failed, and a
+// ParseError reports a response line that could not be parsed; iteration
+// continues after both. If ctx is done, its error is yielded last.
+//
+// Backends that cannot stream are queried a batch at a time and their
+// results yielded once each batch completes.
+func (c *Client) LookupStream(ctx context.Context, ips []string) iter.Seq2[Result, error] {
+	return func(yield func(Result, error) bool) {
+		validIPs, invalidErrs := c.validateIPs(ips)
+
+		for _, e := range invalidErrs {
+			e.Bogon = c.bogonReason(e.IP)
+			if !yield(Result{}, e) {
+				return
+			}
+		}
+
+		queryIPs, localResults := c.classifyIPs(dedupeIPs(validIPs))
+		queryIPs, cachedResults := c.fromCache(queryIPs)
+
+		for _, r := range append(localResults, cachedResults...) {
+			r.Bogon = c.bogonReason(r.IP)
+			if !yield(r, nil) {
+				return
+			}
+		}
+
+		if len(queryIPs) == 0 {
+			return
+		}
+
+		for _, batch := range splitBatches(queryIPs, c.batchSize) {
+			if !c.streamBatch(ctx, batch, yield) {
+				return
+			}
+
+			if err := ctx.Err(); err != nil {
+				yield(Result{}, err)
+				return
+			}
+		}
+	}
+}
+
+// streamBatch queries one batch and yields its results, merging adjacent
+// lines for the same IP and dropping non-adjacent ones, followed by an
+// error for each IP without a result. When a query fails part way, the IPs
+// without a yielded result are retried according to the client's retry
+// policy; a result not yet yielded may be missing lines and is dropped. It
+// returns false if yield asked to stop.
+func (c *Client) streamBatch(ctx context.Context, batch []string, yield func(Result, error) bool) bool {
+	seen := make(map[string]bool, len(batch))
+	failed := make(map[string]error)
+	stopped := false
+
+	var pending *Result
+	flush := func() bool {
+		if pending == nil {
+			return true
+		}
+		r := *pending
+		pending = nil
+		c.storeInCache([]Result{r})
+		r.Bogon = c.bogonReason(r.IP)
+		if !yield(r, nil) {
+			stopped = true
+		}
+		return !stopped
+	}
+
+	onResult := func(r Result) bool {
+		key := canonicalIP(r.IP)
+		if pending != nil && canonicalIP(pending.IP) == key {
+			merged := mergeResults([]Result{*pending, r})[0]
+			pending = &merged
+			return true
+		}
+
+		if seen[key] {
+			return true
+		}
+
+		if !flush() {
+			return false
+		}
+
+		seen[key] = true
+		pending = &r
+		return true
+	}
+
+	onParseError := func(parseErr ParseError) bool {
+		if !yield(Result{}, parseErr) {
+			stopped = true
+		}
+		return !stopped
+	}
+
+	_, err := c.withRetry(ctx, func() error {
+		var remaining []string
+		for _, ip := range batch {
+			if !seen[canonicalIP(ip)] {
+				remaining = append(remaining, ip)
+			}
+		}
+		if len(remaining) == 0 {
+			return nil
+		}
+
+		err := c.streamQuery(ctx, remaining, onResult, onParseError)
+		if stopped {
+			return nil
+		}
+
+		var ipErrs IPErrors
+		if errors.As(err, &ipErrs) {
+			for _, e := range ipErrs {
+				failed[canonicalIP(e.IP)] = e.Err
+			}
+			return nil
+		}
+
+		if err != nil && pending != nil {
+			delete(seen, canonicalIP(pending.IP))
+			pending = nil
+		}
+		return err
+	})
+	if stopped || !flush() {
+		return false
+	}
+
+	for _, ip := range batch {
+		if seen[canonicalIP(ip)] {
+			continue
+		}
+
+		e := LookupError{IP: ip, Err: err}
+		if err == nil {
+			e.Err = failed[canonicalIP(ip)]
+		}
+		if e.Err == nil {
+			e.Err = fmt.Errorf("%w: %s", ErrNoResult, ip)
+		}
+		e.Bogon = c.bogonReason(ip)
+
+		if !yield(Result{}, e) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// streamQuery passes the backend's results for the IPs to the callbacks,
+// as they arrive when the backend can stream them. IPErrors from the
+// backend are returned once the results have been passed on.
+func (c *Client) streamQuery(ctx context.Context, ips []string, onResult func(Result) bool, onParseError func(ParseError) bool) error {
+	if err := c.waitRateLimit(ctx, len(ips)); err != nil {
+		return err
+	}
+
+	if s, ok := c.backend.(resultStreamer); ok {
+		return s.stream(ctx, ips, onResult, onParseError)
+	}
+
+	results, parseErrs, err := c.backend.Query(ctx, ips)
+	var failed IPErrors
+	if err != nil && !errors.As(err, &failed) {
+		return err
+	}
+
+	for _, parseErr := range parseErrs {
+		if !onParseError(parseErr) {
+			return nil
+		}
+	}
+
+	c.trimResults(results)
+	for _, r := range results {
+		if !onResult(r) {
+			return nil
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,155 @@
+package cymruasn
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestLookupStream(t *testing.T) {
+	response := `Bulk mode; whois.cymru.com [2024-01-15 12:00:00 +0000]
+AS      | IP               | BGP Prefix          | CC | AS Name
+15169   | 8.8.8.8          | 8.8.8.0/24          | US | GOOGLE, US
+64500   | 11.0.0.1         | 11.0.0.0/16         | US | EXAMPLE-A, US
+64501   | 11.0.0.1         | 11.0.0.0/16         | US | EXAMPLE-B, US
+garbage
+`
+
+	host, port := startMockServer(t, response)
+	c := NewClient(WithServer(host), WithPort(port), WithTimeout(5*time.Second))
+
+	var results []Result
+	var lookupErrs []LookupError
+	var parseErrs []ParseError
+
+	for r, err := range c.LookupStream(context.Background(), []string{"8.8.8.8", "11.0.0.1", "1.1.1.1", "bogus", "10.0.0.1", "8.8.8.8"}) {
+		var lookupErr LookupError
+		var parseErr ParseError
+
+		switch {
+		case err == nil:
+			results = append(results, r)
+		case errors.As(err, &lookupErr):
+			lookupErrs = append(lookupErrs, lookupErr)
+		case errors.As(err, &parseErr):
+			parseErrs = append(parseErrs, parseErr)
+		default:
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if len(results) != 3 {
+		t.Fatalf("expected 3 results, got %+v", results)
+	}
+
+	if results[0].IP != "10.0.0.1" || results[0].SpecialPurpose == nil {
+		t.Errorf("expected the local result first, got %+v", results[0])
+	}
+
+	if results[1].ASN != 15169 {
+		t.Errorf("expected AS15169, got %+v", results[1])
+	}
+
+	if moas := results[2]; !moas.MOAS || len(moas.ASNs) != 2 {
+		t.Errorf("expected adjacent lines to be merged, got %+v", moas)
+	}
+
+	if len(parseErrs) != 1 || parseErrs[0].Line != "garbage" {
+		t.Errorf("expected a parse error for the garbage line, got %+v", parseErrs)
+	}
+
+	if len(lookupErrs) != 2 || lookupErrs[0].IP != "bogus" || lookupErrs[1].IP != "1.1.1.1" {
+		t.Errorf("expected errors for bogus and 1.1.1.1, got %+v", lookupErrs)
+	}
+}
+
+func TestLookupStreamBreak(t *testing.T) {
+	response := `15169   | 8.8.8.8          | 8.8.8.0/24          | US | GOOGLE, US
+13335   | 1.1.1.1          | 1.1.1.0/24          | US | CLOUDFLARE, US
+`
+
+	host, port := startMockServer(t, response)
+	c := NewClient(WithServer(host), WithPort(port), WithTimeout(5*time.Second))
+
+	n := 0
+	for _, err := range c.LookupStream(context.Background(), []string{"8.8.8.8", "1.1.1.1", "9.9.9.9"}) {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		n++
+		break
+	}
+
+	if n != 1 {
+		t.Errorf("expected iteration to stop after 1 result, got %d", n)
+	}
+}
+
+func TestLookupStreamBatchFailure(t *testing.T) {
+	ips := testIPs(20)
+	backend := &batchBackend{poison: ips[0]}
+	c := NewClient(WithBackend(backend), WithBatchSize(10))
+
+	results := 0
+	var failed []string
+
+	for _, err := range c.LookupStream(context.Background(), ips) {
+		var lookupErr LookupError
+		switch {
+		case err == nil:
+			results++
+		case errors.As(err, &lookupErr) && errors.Is(lookupErr.Err, errBatchFailed):
+			failed = append(failed, lookupErr.IP)
+		default:
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if results != 10 || len(failed) != 10 || failed[0] != ips[0] {
+		t.Errorf("expected 10 results and 10 failed IPs, got %d and %v", results, failed)
+	}
+}
+
+func TestLookupStreamContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	c := NewClient(WithBackend(&stubBackend{}))
+
+	var last error
+	for _, err := range c.LookupStream(ctx, []string{"8.8.8.8"}) {
+		last = err
+	}
+
+	if !errors.Is(last, context.Canceled) {
+		t.Errorf("expected context.Canceled last, got %v", last)
+	}
+}
+
+func TestLookupStreamNonAdjacentLines(t *testing.T) {
+	response := `15169   | 8.8.8.8          | 8.8.8.0/24          | US | GOOGLE, US
+13335   | 1.1.1.1          | 1.1.1.0/24          | US | CLOUDFLARE, US
+3356    | 8.8.8.8          | 8.8.8.0/24          | US | LEVEL3, US
+`
+
+	host, port := startMockServer(t, response)
+	c := NewClient(WithServer(host), WithPort(port), WithTimeout(5*time.Second))
+
+	var results []Result
+	for r, err := range c.LookupStream(context.Background(), []string{"8.8.8.8", "1.1.1.1"}) {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		results = append(results, r)
+	}
+
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %+v", results)
+	}
+
+	if results[0].IP != "8.8.8.8" || !slices.Equal(results[0].ASNs, []uint32{15169}) {
+		t.Errorf("expected the first line for 8.8.8.8 only, got %+v", results[0])
+	}
+}
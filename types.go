@@ -1,6 +1,11 @@
 package cymruasn
 
-import "time"
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
 
 // Result contains the lookup result for a single IP address.
 type Result struct {
@@ -9,18 +14,72 @@ type Result struct {
 	BGPPrefix   string
 	CountryCode string
 	ASName      string
+
+	// Registry and Allocated are only populated when the client is
+	// created with WithVerbose, whichever backend answered.
+	Registry  string
+	Allocated time.Time
+
+	// ASNs lists every origin ASN seen for the prefix, starting with ASN.
+	// MOAS is set when the prefix is announced by more than one origin.
+	ASNs []uint32
+	MOAS bool
+
+	// SyntheticPrefix is set when BGPPrefix is not an announced prefix but
+	// the smallest prefix covering an address range from an offline
+	// dataset, which may take in addresses of neighbouring ranges.
+	SyntheticPrefix bool
+
+	// Delegation is the RIR delegation record covering the IP, set by
+	// Delegations.Enrich.
+	Delegation *Delegation
+
+	// SpecialPurpose is set when the IP lies in a special-purpose block
+	// and was answered locally without a query.
+	SpecialPurpose *SpecialPurpose
+
+	// Bogon explains why the IP is a bogon when the client is created
+	// with WithBogons. It is empty for other IPs.
+	Bogon string
+
+	// Cached is set when the result was answered from the client's cache.
+	Cached bool
+
+	// Server is the whois server, as host:port, that answered the query.
+	// It is empty for results answered locally, from cache or by other
+	// backends.
+	Server string
 }
 
 // LookupError represents a failed lookup for a specific IP.
 type LookupError struct {
 	IP  string
 	Err error
+
+	// Bogon explains why the IP is a bogon when the client is created
+	// with WithBogons. It is empty for other IPs.
+	Bogon string
 }
 
 func (e LookupError) Error() string {
 	return e.Err.Error()
 }
 
+func (e LookupError) Unwrap() error {
+	return e.Err
+}
+
+// IPErrors is returned by a Backend, alongside the results it found, when
+// the lookups of some IPs in a batch failed while others succeeded.
+type IPErrors []LookupError
+
+func (e IPErrors) Error() string {
+	if len(e) == 1 {
+		return fmt.Sprintf("%s: %v", e[0].IP, e[0].Err)
+	}
+	return fmt.Sprintf("%s: %v (and %d more IPs failed)", e[0].IP, e[0].Err, len(e)-1)
+}
+
 // ParseError represents a failed parse of a response line.
 type ParseError struct {
 	Line string
@@ -36,6 +95,88 @@ type Response struct {
 	Results     []Result
 	Errors      []LookupError
 	ParseErrors []ParseError
+
+	// Aligned holds the result for each IP passed to Lookup, in input
+	// order and including duplicates. Entries for IPs without a result
+	// are nil.
+	Aligned []*Result
+
+	// ByIP maps the canonical form of each IP to its result. Use Get to
+	// look up an IP written in any form.
+	ByIP map[string]*Result
+
+	// Attempts is the number of backend queries made, including retries.
+	Attempts int
+
+	// Servers lists the whois servers that answered, in order of first
+	// appearance in Results.
+	Servers []string
+}
+
+// Get returns the result for the given IP, however it is written.
+func (r *Response) Get(ip string) (*Result, bool) {
+	result, ok := r.ByIP[canonicalIP(strings.TrimSpace(ip))]
+	return result, ok
+}
+
+// PeerResult contains the upstream peer ASNs for a single IP address.
+type PeerResult struct {
+	IP          string
+	Peers       []uint32
+	BGPPrefix   string
+	CountryCode string
+	ASName      string
+
+	// Registry and Allocated are only populated when the client is
+	// created with WithVerbose.
+	Registry  string
+	Allocated time.Time
+}
+
+// PeerResponse contains the results of a bulk peer lookup.
+type PeerResponse struct {
+	Results     []PeerResult
+	Errors      []LookupError
+	ParseErrors []ParseError
+}
+
+// ASInfo contains the description of a single autonomous system.
+type ASInfo struct {
+	ASN         uint32
+	CountryCode string
+	Registry    string
+	Allocated   time.Time
+	ASName      string
+
+	// Delegation is the RIR delegation record covering the ASN, set by
+	// Delegations.EnrichASNs.
+	Delegation *Delegation
+}
+
+// ASNLookupError represents a failed lookup for a specific ASN.
+type ASNLookupError struct {
+	ASN uint32
+	Err error
+}
+
+func (e ASNLookupError) Error() string {
+	return e.Err.Error()
+}
+
+// ASNResponse contains the results of a bulk ASN description lookup.
+type ASNResponse struct {
+	Results     []ASInfo
+	Errors      []ASNLookupError
+	ParseErrors []ParseError
+}
+
+// Backend looks up a batch of validated IP addresses. Query returns the
+// results it found, any lines it failed to parse, and a non-nil error only
+// when the batch as a whole failed. IPs it has no answer for are omitted.
+// When only some IPs failed, Query returns the results for the others
+// along with IPErrors for the failed IPs.
+type Backend interface {
+	Query(ctx context.Context, ips []string) ([]Result, []ParseError, error)
 }
 
 // Option configures a Client.
@@ -43,27 +184,62 @@ type Option func(*Client)
 
 // Client performs ASN lookups against Team Cymru's whois service.
 type Client struct {
-	server  string
-	port    string
-	timeout time.Duration
+	server     string
+	peerServer string
+	port       string
+	timeout    time.Duration
+	verbose    bool
+	backend    Backend
+
+	batchSize     int
+	concurrency   int
+	flushInterval time.Duration
+	retry         RetryPolicy
+	limiter       *rateLimiter
+	cache         *Cache
+	inflight      inflightGroup
+	clock         clock
+
+	serverAddrs []string
+	endpoints   []*endpoint
+	cooldown    time.Duration
+
+	specialPurposeCheck bool
+	bogons              *BogonList
 }
 
+// FieldUnavailable marks Result fields that a backend cannot supply, such
+// as the country code and AS name when answering from offline prefix-to-AS
+// data.
+const FieldUnavailable = "-"
+
 // DefaultServer is the default Team Cymru whois server.
 const DefaultServer = "whois.cymru.com"
 
+// DefaultPeerServer is the default Team Cymru peer whois server.
+const DefaultPeerServer = "v4-peer.whois.cymru.com"
+
 // DefaultPort is the default whois port.
 const DefaultPort = "43"
 
 // DefaultTimeout is the default connection timeout.
 const DefaultTimeout = 30 * time.Second
 
-// WithServer sets the whois server address.
+// WithServer sets the whois server address. See WithServers for several
+// servers with failover.
 func WithServer(server string) Option {
 	return func(c *Client) {
 		c.server = server
 	}
 }
 
+// WithPeerServer sets the peer whois server address.
+func WithPeerServer(server string) Option {
+	return func(c *Client) {
+		c.peerServer = server
+	}
+}
+
 // WithPort sets the whois server port.
 func WithPort(port string) Option {
 	return func(c *Client) {
@@ -77,3 +253,67 @@ func WithTimeout(timeout time.Duration) Option {
 		c.timeout = timeout
 	}
 }
+
+// WithVerbose requests verbose output, which adds the RIR registry and
+// allocation date to each result.
+func WithVerbose(verbose bool) Option {
+	return func(c *Client) {
+		c.verbose = verbose
+	}
+}
+
+// WithBatchSize sets the maximum number of IPs Lookup sends to the backend
+// in one query. Larger inputs are split into several batches. A size of
+// zero or less sends every IP in one query.
+func WithBatchSize(size int) Option {
+	return func(c *Client) {
+		c.batchSize = size
+	}
+}
+
+// WithConcurrency sets the maximum number of batches Lookup queries at
+// once, each on its own connection.
+func WithConcurrency(n int) Option {
+	return func(c *Client) {
+		c.concurrency = n
+	}
+}
+
+// WithFlushInterval sets how long LookupSeq and LookupReader wait for a
+// batch to fill before querying it anyway.
+func WithFlushInterval(interval time.Duration) Option {
+	return func(c *Client) {
+		c.flushInterval = interval
+	}
+}
+
+// WithSpecialPurposeCheck controls whether IPs in special-purpose blocks,
+// such as private-use, documentation and loopback addresses, are answered
+// locally instead of being sent to the backend. It is enabled by default.
+func WithSpecialPurposeCheck(enabled bool) Option {
+	return func(c *Client) {
+		c.specialPurposeCheck = enabled
+	}
+}
+
+// WithBogons flags results and lookup errors for IPs in the given bogon
+// list with a bogon reason.
+func WithBogons(bogons *BogonList) Option {
+	return func(c *Client) {
+		c.bogons = bogons
+	}
+}
+
+// WithBackend sets the backend used by Lookup. By default the client
+// queries the bulk whois server.
+func WithBackend(backend Backend) Option {
+	return func(c *Client) {
+		c.backend = backend
+	}
+}
+
+// WithDNSBackend makes Lookup query Team Cymru's DNS interface through d
+// instead of the bulk whois server. It is shorthand for WithBackend(d).
+func WithDNSBackend(d *DNSBackend) Option {
+	return WithBackend(d)
+}